@@ -0,0 +1,121 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// baseline holds the broken urls which are already known, loaded from the
+// file given via -baseline, so that only new broken urls are reported.
+//
+// Entries are keyed by file and url rather than by position, so that they
+// still apply after the lines around a url change. The file has an entry per
+// line, with a file path followed by a space and a url. Empty lines and lines
+// starting with "#" are skipped.
+type baseline struct {
+	path  string
+	known map[string]map[string]bool
+
+	// found is the set of broken urls found in each file scanned so far,
+	// including files without any broken urls.
+	found map[string]map[string]bool
+}
+
+var knownBroken *baseline
+
+const baselineHeader = `# Broken urls known to xurls, which are not reported; see -baseline.
+# Each line is a file path followed by a url.
+`
+
+// loadBaseline loads a baseline file. A missing file is only allowed
+// with -update-baseline, as it will be created.
+func loadBaseline(path string, update bool) (*baseline, error) {
+	b := &baseline{
+		path:  path,
+		known: make(map[string]map[string]bool),
+		found: make(map[string]map[string]bool),
+	}
+	err := readLines(path, func(line string) error {
+		// Urls never contain spaces, but file paths might.
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return errors.New("expected a file path and a url")
+		}
+		file, url := baselinePath(strings.TrimSpace(line[:i])), line[i+1:]
+		if b.known[file] == nil {
+			b.known[file] = make(map[string]bool)
+		}
+		b.known[file][url] = true
+		return nil
+	})
+	if os.IsNotExist(err) && update {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// baselinePath normalizes a file path for the baseline, so that paths like
+// "doc.md" and "./doc.md" are the same entry.
+func baselinePath(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// filter records the broken urls found in a file, and returns the findings
+// without the broken urls which are in the baseline. With -update-baseline,
+// no broken urls are returned, as they are all added to the baseline.
+func (b *baseline) filter(file string, findings []finding) []finding {
+	file = baselinePath(file)
+	found := make(map[string]bool)
+	b.found[file] = found
+	var kept []finding
+	for _, f := range findings {
+		if f.kind == brokenFinding {
+			found[f.url] = true
+			if b.known[file][f.url] || *updateBaseline {
+				continue
+			}
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// fixed returns the entries in the baseline for the files scanned so far
+// which are no longer broken, such as "README.md https://example.com".
+func (b *baseline) fixed() []string {
+	var entries []string
+	for _, file := range slices.Sorted(maps.Keys(b.found)) {
+		for _, url := range slices.Sorted(maps.Keys(b.known[file])) {
+			if !b.found[file][url] {
+				entries = append(entries, file+" "+url)
+			}
+		}
+	}
+	return entries
+}
+
+// write replaces the baseline file with the broken urls found in the files
+// scanned so far. Entries for any other files are kept as they were.
+func (b *baseline) write() error {
+	all := maps.Clone(b.known)
+	maps.Copy(all, b.found)
+	var sb strings.Builder
+	sb.WriteString(baselineHeader)
+	for _, file := range slices.Sorted(maps.Keys(all)) {
+		for _, url := range slices.Sorted(maps.Keys(all[file])) {
+			fmt.Fprintf(&sb, "%s %s\n", file, url)
+		}
+	}
+	return os.WriteFile(b.path, []byte(sb.String()), 0o666)
+}
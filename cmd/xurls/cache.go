@@ -0,0 +1,165 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// linkCache is an on-disk cache of the results of checkURL, for -cache-dir.
+// Each url is stored as a JSON file named after the hash of its cache key.
+type linkCache struct {
+	dir string
+
+	ttl       time.Duration // for urls which loaded fine
+	brokenTTL time.Duration // for broken urls
+
+	// refresh ignores any cached results, while still storing new ones.
+	refresh bool
+}
+
+// cacheEntry is the JSON encoding of a checkResult in the cache.
+type cacheEntry struct {
+	URL       string        `json:"url"`
+	Fixed     string        `json:"fixed"`
+	Status    string        `json:"status"`
+	Code      int           `json:"code,omitempty"`
+	Broken    bool          `json:"broken"`
+	Dead      bool          `json:"dead,omitempty"`
+	Method    string        `json:"method,omitempty"`
+	Redirects []redirectHop `json:"redirects,omitempty"`
+	Checked   time.Time     `json:"checked"`
+}
+
+// cacheKey returns the key for a url in the cache. Since the result of
+// checking a url depends on which redirects we follow, that is included too,
+// as well as the other settings which may change the result; see
+// settingsHash.
+func cacheKey(u *url.URL) string {
+	return redirects + " " + cacheSettings + " " + u.String()
+}
+
+// cacheSettings is set by setupHTTP to the result of settingsHash.
+var cacheSettings string
+
+// settingsHash returns a short hash of the settings which may change the
+// result of loading a url, so that changing any of them, such as adding
+// an authorization header, doesn't reuse results cached before the change.
+func settingsHash(cfg *config, headers []hostHeaders) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "timeout %v\n", time.Duration(cfg.Timeout))
+	fmt.Fprintf(h, "user-agent %q\n", cfg.UserAgent)
+	fmt.Fprintf(h, "proxy %q\n", cfg.Proxy)
+	fmt.Fprintf(h, "get-on %v\n", cfg.GetOn)
+	fmt.Fprintf(h, "max-redirects %d\n", *cfg.MaxRedirects)
+	fmt.Fprintf(h, "tls %q %q %q\n", cfg.CAFile, cfg.CertFile, cfg.KeyFile)
+	for _, hh := range headers {
+		fmt.Fprintf(h, "host %q\n", hh.host)
+		for _, name := range slices.Sorted(maps.Keys(hh.header)) {
+			fmt.Fprintf(h, "header %q %q\n", name, hh.header[name])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func (c *linkCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached result for a key, if any. Results which are older
+// than their TTL are ignored, as are files which cannot be read or decoded.
+func (c *linkCache) get(key string) (checkResult, bool) {
+	if c.refresh {
+		return checkResult{}, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return checkResult{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return checkResult{}, false
+	}
+	ttl := c.ttl
+	if entry.Broken {
+		ttl = c.brokenTTL
+	}
+	if time.Since(entry.Checked) > ttl {
+		return checkResult{}, false
+	}
+	return checkResult{
+		fixed:     entry.Fixed,
+		status:    entry.Status,
+		code:      entry.Code,
+		broken:    entry.Broken,
+		dead:      entry.Dead,
+		method:    entry.Method,
+		redirects: entry.Redirects,
+	}, true
+}
+
+// put stores a result for a key. The file is written atomically, so that
+// concurrent runs never see a partially written entry.
+// Any error is ignored, as the cache is only an optimization.
+func (c *linkCache) put(key string, u *url.URL, res checkResult) {
+	data, err := json.Marshal(cacheEntry{
+		URL:       u.String(),
+		Fixed:     res.fixed,
+		Status:    res.status,
+		Code:      res.code,
+		Broken:    res.broken,
+		Dead:      res.dead,
+		Method:    res.method,
+		Redirects: res.redirects,
+		Checked:   time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// cache is set by -cache-dir.
+var cache *linkCache
+
+// cachedCheckURL is like checkURL, but it consults and fills the cache
+// if -cache-dir is used.
+func cachedCheckURL(ctx context.Context, u *url.URL) checkResult {
+	if cache == nil {
+		return checkURL(ctx, u)
+	}
+	key := cacheKey(u)
+	if res, ok := cache.get(key); ok {
+		return res
+	}
+	res := checkURL(ctx, u)
+	if !res.canceled {
+		cache.put(key, u, res)
+	}
+	return res
+}
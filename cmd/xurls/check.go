@@ -0,0 +1,297 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// checkResult is the result of loading a url with checkURL.
+type checkResult struct {
+	// fixed is the url which should replace the original one.
+	// It is empty if the url could not be loaded at all.
+	fixed string
+
+	// status is the final status, such as "200 OK", or an error message.
+	status string
+
+	// code is the final HTTP status code, if any.
+	code int
+
+	broken bool
+
+	// dead is set for broken urls which are likely gone for good,
+	// as they gave a 404 or 410 status code, or their host doesn't exist.
+	dead bool
+
+	// method is the HTTP method of the request which gave the final status,
+	// as HEAD requests may be retried with GET; see -get-on.
+	method string
+
+	// redirects holds the redirects which were followed, in order.
+	redirects []redirectHop
+
+	// upgraded is set when an http url is replaced with its https version.
+	// insecure is set when an http url has no working https version,
+	// explaining why. Both are only used with -fix=https or -check=https.
+	upgraded bool
+	insecure string
+
+	// warning is set instead of broken by a "warn" host policy,
+	// explaining why the url would have been broken.
+	warning string
+
+	// canceled is set when the url wasn't loaded because we were interrupted.
+	canceled bool
+}
+
+// redirectHop is a url which responded with a redirect status code
+// pointing to a target url.
+type redirectHop struct {
+	URL    string `json:"url"`
+	Code   int    `json:"status"`
+	Target string `json:"target"`
+}
+
+// checkMatch checks a url found in the input. Urls which cannot be parsed are
+// broken, and urls with schemes other than http and https are not loaded,
+// nor are urls whose host has a "skip" policy.
+// Each distinct url is only loaded once per run; see checkGroup.
+func checkMatch(ctx context.Context, match string) checkResult {
+	u, err := url.Parse(match)
+	if err != nil {
+		return checkResult{status: err.Error(), broken: true}
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && policyFor(u.Hostname()).Action == "skip" {
+		return checkResult{fixed: match, status: "skipped by policy"}
+	}
+	switch u.Scheme {
+	case "http":
+		res := checkHTTP(ctx, u)
+		if upgradeHTTPS && !res.broken && !res.canceled {
+			res = upgradeToHTTPS(ctx, u, res)
+		}
+		return res
+	case "https":
+		return checkHTTP(ctx, u)
+	}
+	return checkResult{fixed: match}
+}
+
+// checkHTTP loads an http or https url, applying its host's policy.
+// The policy is applied after the cache, so that changing it takes effect
+// right away.
+func checkHTTP(ctx context.Context, u *url.URL) checkResult {
+	res := checks.do(cacheKey(u), func() checkResult {
+		res := cachedCheckURL(ctx, u)
+		if *fragments {
+			res = checkFragment(ctx, res)
+		}
+		return res
+	})
+	return policyFor(u.Hostname()).apply(res)
+}
+
+// upgradeToHTTPS tries the https version of an http url which loaded fine.
+// If it loads fine too, and it ends up at the same url ignoring the scheme,
+// the url is replaced with the https version.
+func upgradeToHTTPS(ctx context.Context, u *url.URL, res checkResult) checkResult {
+	if strings.HasPrefix(res.fixed, "https://") {
+		// Already redirected to https.
+		res.upgraded = true
+		return res
+	}
+	secure := *u
+	secure.Scheme = "https"
+	if secure.Port() == "80" {
+		secure.Host = strings.TrimSuffix(secure.Host, ":80")
+	}
+	secureRes := checkHTTP(ctx, &secure)
+	switch {
+	case secureRes.canceled:
+		res.canceled = true
+	case secureRes.broken:
+		res.insecure = secureRes.status
+	case !sameIgnoringScheme(res.fixed, secureRes.fixed):
+		res.insecure = "https version leads to " + secureRes.fixed
+	default:
+		secureRes.upgraded = true
+		return secureRes
+	}
+	return res
+}
+
+// sameIgnoringScheme reports whether two urls are equal except for their
+// schemes and default ports.
+func sameIgnoringScheme(url1, url2 string) bool {
+	u1, err1 := url.Parse(url1)
+	u2, err2 := url.Parse(url2)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	for _, u := range []*url.URL{u1, u2} {
+		if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+			u.Host = strings.TrimSuffix(u.Host, ":"+port)
+		}
+		u.Scheme = ""
+	}
+	return u1.String() == u2.String()
+}
+
+// checkGroup deduplicates the loading of urls within a single run,
+// much like golang.org/x/sync/singleflight, but also keeping the results.
+// This way, a url which appears in many lines or files is loaded only once,
+// and all of its occurrences get the same result.
+type checkGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*checkCall[T]
+}
+
+type checkCall[T any] struct {
+	done chan struct{} // closed once res is set
+	res  T
+}
+
+var checks = checkGroup[checkResult]{calls: make(map[string]*checkCall[checkResult])}
+
+// do returns the result of fn for a key, calling fn only the first time that
+// a key is seen. Concurrent calls for the same key wait for the first one.
+//
+// Note that fn must not block on a reporter, as a sequencer task waiting on
+// the result could then deadlock with the task running fn.
+func (g *checkGroup[T]) do(key string, fn func() T) T {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.res
+	}
+	c := &checkCall[T]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.res = fn()
+	close(c.done)
+	return c.res
+}
+
+var errRedirectLoop = errors.New("redirect loop")
+
+// redirectLimit enforces -max-redirects before following a redirect.
+// With a limit of zero, redirects are not followed at all.
+func redirectLimit(via []*http.Request) error {
+	limit := *httpConfig.MaxRedirects
+	switch {
+	case limit == 0:
+		return http.ErrUseLastResponse
+	case len(via) > limit:
+		return fmt.Errorf("stopped after %d redirects", limit)
+	}
+	return nil
+}
+
+func withoutFragment(u *url.URL) string {
+	u2 := *u
+	u2.Fragment = ""
+	u2.RawFragment = ""
+	return u2.String()
+}
+
+// checkURL loads an http or https url to see if it's broken or if it
+// redirects elsewhere, following redirects as allowed by -fix or -check.
+//
+// A HEAD request is sent first, as we don't need the body. Since many servers
+// don't implement HEAD properly, a status code listed in -get-on causes a
+// retry with a GET request, which asks for a single byte via a Range header.
+func checkURL(ctx context.Context, origURL *url.URL) (res checkResult) {
+	res.fixed = origURL.String()
+	client := &http.Client{
+		Transport: transport, // which also implements a timeout per request
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := redirectLimit(via); err != nil {
+				return err
+			}
+			switch req.Response.StatusCode {
+			case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+				// "auto" and "all" fix permanent redirects.
+			case http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect:
+				// Only "all" fixes temporary redirects.
+				if redirects != "all" {
+					return http.ErrUseLastResponse
+				}
+			default:
+				// Any other redirects are ignored.
+				return http.ErrUseLastResponse
+			}
+			// Inherit the fragment if empty.
+			if req.URL.Fragment == "" {
+				req.URL.Fragment = origURL.Fragment
+			}
+			target := req.URL.String()
+			res.redirects = append(res.redirects, redirectHop{res.fixed, req.Response.StatusCode, target})
+			if slices.ContainsFunc(via, func(prev *http.Request) bool {
+				return withoutFragment(prev.URL) == withoutFragment(req.URL)
+			}) {
+				return errRedirectLoop
+			}
+			res.fixed = target
+			return nil
+		},
+	}
+	method := http.MethodHead
+	ranged := false
+retry:
+	req, err := http.NewRequestWithContext(ctx, method, res.fixed, nil)
+	if err != nil {
+		return checkResult{status: err.Error(), broken: true, method: method, redirects: res.redirects}
+	}
+	req.Header.Set("User-Agent", httpConfig.UserAgent)
+	if ranged {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil && ctx.Err() != nil {
+		return checkResult{canceled: true}
+	}
+	if errors.Is(err, errRedirectLoop) {
+		return checkResult{status: errRedirectLoop.Error(), broken: true, method: method, redirects: res.redirects}
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		dead := errors.As(err, &dnsErr) && dnsErr.IsNotFound
+		return checkResult{status: err.Error(), broken: true, dead: dead, method: method, redirects: res.redirects}
+	}
+	// Servers may ignore the Range header, so don't read the entire body.
+	// Reading a bit of it still allows reusing the connection in most cases.
+	io.CopyN(io.Discard, resp.Body, 4<<10)
+	resp.Body.Close()
+	res.code = resp.StatusCode
+	res.method = method
+	switch {
+	case method == http.MethodHead && slices.Contains(httpConfig.GetOn, res.code):
+		method, ranged = http.MethodGet, true
+		goto retry
+	case ranged && res.code == http.StatusRequestedRangeNotSatisfiable:
+		// The body is likely empty; retry without asking for a range.
+		ranged = false
+		goto retry
+	}
+	res.status = strconv.Itoa(res.code)
+	if text := http.StatusText(res.code); text != "" {
+		res.status += " " + text
+	}
+	res.broken = res.code >= 400
+	res.dead = res.code == http.StatusNotFound || res.code == http.StatusGone
+	return res
+}
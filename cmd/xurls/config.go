@@ -0,0 +1,218 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config is the JSON configuration file given via -config, which configures
+// how urls are loaded with -fix and -check. For example:
+//
+//	{
+//		"timeout": "30s",
+//		"maxRedirects": 5,
+//		"userAgent": "my-link-checker",
+//		"proxy": "http://proxy.internal:3128",
+//		"getOn": [403, 405],
+//		"caFile": "corporate-ca.pem",
+//		"certFile": "client.pem",
+//		"keyFile": "client-key.pem",
+//		"headers": {
+//			"*.docs.internal": {"Authorization": "Bearer ${DOCS_TOKEN}"}
+//		},
+//		"policies": {
+//			"*linkedin.com": {"accept": [999]},
+//			"api.internal": {"accept": [401, 403]},
+//			"twitter.com": {"action": "skip"}
+//		}
+//	}
+//
+// Header values may reference environment variables, so that secrets don't
+// need to be part of the file. Relative file paths are relative to the
+// current directory.
+type config struct {
+	Timeout   duration `json:"timeout"`
+	UserAgent string   `json:"userAgent"`
+	Proxy     string   `json:"proxy"`
+
+	// MaxRedirects is a pointer, as zero means not following any redirects.
+	MaxRedirects *int `json:"maxRedirects"`
+
+	// GetOn lists the status codes for which HEAD requests are retried
+	// with GET.
+	GetOn []int `json:"getOn"`
+
+	CAFile   string `json:"caFile"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+
+	// Headers maps host patterns, as used by -host, to extra headers to send
+	// in requests to matching hosts.
+	Headers map[string]map[string]string `json:"headers"`
+
+	// Policies maps host patterns, as used by -host, to how the results of
+	// loading urls from matching hosts are treated.
+	Policies map[string]hostPolicy `json:"policies"`
+}
+
+// duration is a time.Duration encoded in JSON as a string like "30s".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// hostHeaders are extra headers to send to hosts matching a pattern.
+type hostHeaders struct {
+	host   *regexp.Regexp
+	header http.Header
+}
+
+// headerList is a flag which can be given multiple times,
+// each time adding a header like "Name: value".
+type headerList []string
+
+func (l *headerList) String() string { return strings.Join(*l, ", ") }
+
+func (l *headerList) Set(val string) error {
+	if name, _, ok := strings.Cut(val, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header must be like %q", "Name: value")
+	}
+	*l = append(*l, val)
+	return nil
+}
+
+// statusList is a flag holding a comma-separated list of HTTP status codes.
+type statusList []int
+
+func (l *statusList) String() string {
+	var strs []string
+	for _, code := range *l {
+		strs = append(strs, strconv.Itoa(code))
+	}
+	return strings.Join(strs, ",")
+}
+
+func (l *statusList) Set(val string) error {
+	var list []int
+	for field := range strings.SplitSeq(val, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil || code < 100 || code > 999 {
+			return fmt.Errorf("invalid status code: %q", field)
+		}
+		list = append(list, code)
+	}
+	// An empty list is not nil, so that it overrides the configuration file.
+	*l = append(statusList{}, list...)
+	return nil
+}
+
+// newHTTPTransport builds the base transport for loading urls,
+// with any proxy and TLS settings from the configuration.
+func newHTTPTransport(cfg *config) (*http.Transport, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" {
+		return tr, nil
+	}
+	tlsConfig := &tls.Config{}
+	if cfg.CAFile != "" {
+		pemData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		// Add to the system's certificates, so that public hosts still work.
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	tr.TLSClientConfig = tlsConfig
+	return tr, nil
+}
+
+// parseHeaders compiles the per-host headers from the configuration,
+// followed by the headers from -header which apply to all hosts.
+func parseHeaders(cfg *config, flagHeaders []string) ([]hostHeaders, error) {
+	var list []hostHeaders
+	// Sorted, so that the order in which headers are set is deterministic.
+	for _, pattern := range slices.Sorted(maps.Keys(cfg.Headers)) {
+		fields := cfg.Headers[pattern]
+		rx, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		h := make(http.Header)
+		for name, value := range fields {
+			h.Set(name, os.ExpandEnv(value))
+		}
+		list = append(list, hostHeaders{rx, h})
+	}
+	if len(flagHeaders) > 0 {
+		h := make(http.Header)
+		for _, field := range flagHeaders {
+			name, value, _ := strings.Cut(field, ":")
+			h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		list = append(list, hostHeaders{regexp.MustCompile(""), h})
+	}
+	return list, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fixDead is set by -fix=dead, which replaces dead urls via -dead-map or
+// -archive, or else annotates them with -dead-marker.
+var fixDead bool
+
+// deadMap is loaded from -dead-map, mapping dead urls to their replacements.
+var deadMap map[string]string
+
+// loadDeadMap loads a file with a dead url and its replacement per line,
+// separated by whitespace. Empty lines and lines starting with "#" are skipped.
+func loadDeadMap(path string) (map[string]string, error) {
+	m := make(map[string]string)
+	err := readLines(path, func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return errors.New("expected a dead url and its replacement")
+		}
+		m[fields[0]] = fields[1]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// fixDeadURL returns the text which should replace a dead url, given the text
+// which follows it in the line. The replacement is, in order of preference:
+// the url from -dead-map, the latest archived version from -archive, or the
+// url followed by -dead-marker. An empty string is returned if none apply.
+//
+// Urls which are already followed by the marker are returned as they are,
+// as they were annotated by a previous run.
+func fixDeadURL(ctx context.Context, match, rest string) string {
+	if *deadMarker != "" && strings.HasPrefix(rest, *deadMarker) {
+		return match
+	}
+	if replacement := deadMap[match]; replacement != "" {
+		return replacement
+	}
+	if *archiveURL != "" {
+		u, err := url.Parse(match)
+		if err == nil {
+			fragment := u.EscapedFragment()
+			u.Fragment, u.RawFragment = "", ""
+			snapshot := archived.do(u.String(), func() string {
+				return lookupArchive(ctx, u.String())
+			})
+			if snapshot != "" {
+				if fragment != "" && !strings.Contains(snapshot, "#") {
+					snapshot += "#" + fragment
+				}
+				return snapshot
+			}
+		}
+	}
+	if *deadMarker != "" {
+		return match + *deadMarker
+	}
+	return ""
+}
+
+// archived deduplicates the archive lookups for urls.
+var archived = checkGroup[string]{calls: make(map[string]*checkCall[string])}
+
+// archiveResponse is the response of an archive lookup endpoint,
+// in the format of the Wayback Machine's availability API:
+// https://archive.org/help/wayback_api.php
+type archiveResponse struct {
+	ArchivedSnapshots struct {
+		Closest struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// lookupArchive asks the -archive endpoint for the closest archived version
+// of a url which loaded fine, returning its url. An empty string is returned
+// if there is none, or if the lookup failed.
+func lookupArchive(ctx context.Context, pageURL string) string {
+	endpoint, err := url.Parse(*archiveURL)
+	if err != nil {
+		return ""
+	}
+	query := endpoint.Query()
+	query.Set("url", pageURL)
+	endpoint.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", httpConfig.UserAgent)
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	var archive archiveResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&archive); err != nil {
+		return ""
+	}
+	closest := archive.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Status != "200" {
+		return ""
+	}
+	return closest.URL
+}
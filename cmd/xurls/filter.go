@@ -0,0 +1,93 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// patternList is a flag which can be given multiple times,
+// each time adding a glob or regular expression pattern.
+type patternList []*regexp.Regexp
+
+func (l *patternList) String() string {
+	var strs []string
+	for _, rx := range *l {
+		strs = append(strs, rx.String())
+	}
+	return strings.Join(strs, ",")
+}
+
+func (l *patternList) Set(val string) error {
+	rx, err := compilePattern(val)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, rx)
+	return nil
+}
+
+// compilePattern compiles a pattern given on the command line.
+// A pattern surrounded by slashes, such as "/^https?:/", is an unanchored
+// regular expression. Any other pattern is a glob matching the entire
+// string, where "*" matches any sequence of characters and "?" matches any
+// single character. Patterns are case-insensitive.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+	}
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// matchesAny reports whether s matches any of the patterns.
+func (l patternList) matchesAny(s string) bool {
+	for _, rx := range l {
+		if rx.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	includeHosts patternList
+	excludeHosts patternList
+	includeURLs  patternList
+	excludeURLs  patternList
+)
+
+// filterAllows reports whether a match is kept by the -host, -exclude-host,
+// -include and -exclude flags. When any -host or -include patterns are given,
+// a match must match at least one of them. A match must not match any of the
+// -exclude-host or -exclude patterns.
+func filterAllows(match string) bool {
+	if len(includeURLs) > 0 && !includeURLs.matchesAny(match) {
+		return false
+	}
+	if excludeURLs.matchesAny(match) {
+		return false
+	}
+	if len(includeHosts) == 0 && len(excludeHosts) == 0 {
+		return true
+	}
+	host := newMatchInfo("", 0, 0, match).Host
+	if len(includeHosts) > 0 && !includeHosts.matchesAny(host) {
+		return false
+	}
+	return !excludeHosts.matchesAny(host)
+}
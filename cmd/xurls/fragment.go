@@ -0,0 +1,249 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxPageSize is how much of an HTML page we read to find its anchors.
+const maxPageSize = 8 << 20
+
+// pageAnchors is the result of loading an HTML page with loadAnchors.
+type pageAnchors struct {
+	// anchors is the set of fragments which the page defines.
+	// It is nil if the page isn't HTML, so its fragments can't be checked.
+	anchors map[string]bool
+
+	// err is set if the page could not be loaded.
+	err error
+}
+
+// anchors deduplicates the loading of pages, as many urls with different
+// fragments tend to point to the same page.
+var anchors = checkGroup[pageAnchors]{calls: make(map[string]*checkCall[pageAnchors])}
+
+// checkFragment checks that the fragment of a url which loaded fine, if any,
+// points to an existing anchor in its HTML page. If not, the url is broken,
+// and the closest existing anchor is suggested.
+func checkFragment(ctx context.Context, res checkResult) checkResult {
+	if res.broken || res.fixed == "" {
+		return res
+	}
+	u, err := url.Parse(res.fixed)
+	if err != nil || !checkableFragment(u.Fragment) {
+		return res
+	}
+	fragment := u.Fragment
+	u.Fragment = ""
+	u.RawFragment = ""
+	page := anchors.do(u.String(), func() pageAnchors {
+		return loadAnchors(ctx, u.String())
+	})
+	switch {
+	case page.err != nil && ctx.Err() != nil:
+		res.canceled = true
+	case page.err != nil:
+		res.status = fmt.Sprintf("cannot check fragment %q: %v", fragment, page.err)
+		res.broken = true
+	case page.anchors == nil, page.anchors[fragment]:
+	default:
+		res.status = fmt.Sprintf("missing fragment %q", fragment)
+		if closest := closestAnchor(fragment, page.anchors); closest != "" {
+			res.status += fmt.Sprintf("; did you mean %q?", closest)
+		}
+		res.broken = true
+	}
+	return res
+}
+
+// checkableFragment reports whether a fragment should point to an anchor.
+// Fragments such as "#/path" or "#!/path" are often used for client-side
+// routing, "#:~:text=" fragments highlight text, and "#top" always works.
+func checkableFragment(fragment string) bool {
+	switch {
+	case fragment == "", strings.EqualFold(fragment, "top"):
+		return false
+	case strings.HasPrefix(fragment, "/"), strings.HasPrefix(fragment, "!"):
+		return false
+	case strings.HasPrefix(fragment, ":~:"):
+		return false
+	}
+	return true
+}
+
+// loadAnchors loads a page with a GET request and collects its anchors.
+func loadAnchors(ctx context.Context, pageURL string) pageAnchors {
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return redirectLimit(via)
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return pageAnchors{err: err}
+	}
+	req.Header.Set("User-Agent", httpConfig.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return pageAnchors{err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// We already know the url loads fine with HEAD;
+		// we simply can't check its fragment.
+		return pageAnchors{}
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return pageAnchors{}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return pageAnchors{err: err}
+	}
+	return pageAnchors{anchors: findAnchors(bytes.NewReader(body))}
+}
+
+// findAnchors finds the anchors defined by an HTML page: the values of "id"
+// attributes and of "name" attributes in links, as well as the anchors which
+// GitHub generates for headings in rendered markdown. Comments, scripts and
+// styles are skipped.
+//
+// GitHub prefixes the ids in user content with "user-content-", and then
+// uses JavaScript to scroll to "#foo" when there is an "user-content-foo" id.
+// Headings without ids are given anchors like GitHub does as well,
+// so that pages rendering markdown in a similar way are supported too.
+func findAnchors(page io.Reader) map[string]bool {
+	set := make(map[string]bool)
+	slugCount := make(map[string]int)
+	var heading *strings.Builder // the text of the current heading, if any
+	inScript := false            // in a script or style, whose text is skipped
+	z := html.NewTokenizer(page)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return set
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			for _, attr := range tok.Attr {
+				// Only links define anchors via "name"; see the HTML spec's
+				// "find a potential indicated element".
+				if attr.Key != "id" && (attr.Key != "name" || tok.DataAtom != atom.A) {
+					continue
+				}
+				set[attr.Val] = true
+				if name, ok := strings.CutPrefix(attr.Val, "user-content-"); ok {
+					set[name] = true
+				}
+			}
+			switch {
+			case isHeading(tok.DataAtom):
+				heading = new(strings.Builder)
+			case tok.DataAtom == atom.Script, tok.DataAtom == atom.Style:
+				inScript = tok.Type == html.StartTagToken
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			if tok.DataAtom == atom.Script || tok.DataAtom == atom.Style {
+				inScript = false
+			}
+			if !isHeading(tok.DataAtom) || heading == nil {
+				continue
+			}
+			slug := headingSlug(heading.String())
+			heading = nil
+			if slug == "" {
+				continue
+			}
+			// Repeated headings get suffixes, like "intro", "intro-1", "intro-2".
+			n := slugCount[slug]
+			slugCount[slug]++
+			if n > 0 {
+				slug = fmt.Sprintf("%s-%d", slug, n)
+			}
+			set[slug] = true
+		case html.TextToken:
+			// The tokenizer gives the contents of scripts and styles as
+			// text, so tags in them are never mistaken for anchors.
+			if heading != nil && !inScript {
+				heading.Write(z.Text())
+			}
+		}
+	}
+}
+
+func isHeading(a atom.Atom) bool {
+	switch a {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+// headingSlug returns the anchor for a heading, following GitHub's rules:
+// lowercase, spaces turned into hyphens, and punctuation removed.
+func headingSlug(heading string) string {
+	heading = strings.TrimSpace(heading)
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-', r == '_', unicode.IsLetter(r), unicode.IsNumber(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// closestAnchor returns the anchor which is closest to a fragment by edit
+// distance, or an empty string if none is close enough to be a likely fix.
+func closestAnchor(fragment string, anchors map[string]bool) string {
+	best, bestDist := "", max(2, len(fragment)/2)+1
+	for anchor := range anchors {
+		dist := editDistance(strings.ToLower(fragment), strings.ToLower(anchor))
+		// Break ties deterministically.
+		if dist < bestDist || (dist == bestDist && best != "" && anchor < best) {
+			best, bestDist = anchor, dist
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings,
+// counting runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
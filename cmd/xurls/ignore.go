@@ -0,0 +1,93 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile holds the patterns loaded from the file given via -ignore.
+// Urls matching any of them aren't loaded, reported, or rewritten.
+//
+// Each line in the file is a pattern like the ones for -include,
+// or a host pattern like the ones for -host when prefixed with "host:".
+// Empty lines and lines starting with "#" are skipped. For example:
+//
+//	# examples which only work locally
+//	host:localhost
+//	host:*.internal
+//	https://example.com/private/*
+type ignoreFile struct {
+	urls  patternList
+	hosts patternList
+}
+
+var ignores ignoreFile
+
+func loadIgnoreFile(path string) (ignoreFile, error) {
+	var ig ignoreFile
+	err := readLines(path, func(line string) error {
+		list := &ig.urls
+		if rest, ok := strings.CutPrefix(line, "host:"); ok {
+			line = strings.TrimSpace(rest)
+			list = &ig.hosts
+		}
+		return list.Set(line)
+	})
+	if err != nil {
+		return ignoreFile{}, err
+	}
+	return ig, nil
+}
+
+// readLines calls fn with each line in a file, trimming spaces and skipping
+// empty lines and lines starting with "#". Errors from fn are prefixed with
+// the file path and line number.
+func readLines(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// ignored reports whether a url matches any of the patterns.
+func (ig *ignoreFile) ignored(match string) bool {
+	if ig.urls.matchesAny(match) {
+		return true
+	}
+	return len(ig.hosts) > 0 && ig.hosts.matchesAny(newMatchInfo("", 0, 0, match).Host)
+}
+
+// rxIgnoreDirective matches the directives which can be used in the input to
+// ignore the urls in the same line, or in the next line.
+var rxIgnoreDirective = regexp.MustCompile(`xurls:ignore(-next-line)?\b`)
+
+// ignoreDirectives reports whether a line has directives to ignore its urls,
+// or the urls in the next line.
+func ignoreDirectives(line string) (thisLine, nextLine bool) {
+	for _, m := range rxIgnoreDirective.FindAllStringSubmatch(line, -1) {
+		if m[1] != "" {
+			nextLine = true
+		} else {
+			thisLine = true
+		}
+	}
+	return thisLine, nextLine
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limitedTransport is an http.RoundTripper which limits the number of
+// concurrent requests and the rate of requests per host, and which retries
+// requests that were rate limited by the server.
+//
+// Since requests may wait for a while before being sent,
+// the timeout for each request only starts once it is sent.
+// Similarly, canceling a request's context only stops it if it hasn't been
+// sent yet, so that any requests in flight are completed when interrupted.
+type limitedTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+	headers []hostHeaders
+
+	hostConcurrency int           // per host; 0 means no limit
+	hostInterval    time.Duration // between requests to each host
+
+	retries   int           // for 429 and 503 responses
+	retryWait time.Duration // before the first retry, doubling after that
+	maxWait   time.Duration // maximum time to wait before any retry
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimit
+}
+
+type hostLimit struct {
+	sem  chan struct{} // nil if there's no concurrency limit
+	next time.Time     // earliest time for the next request
+}
+
+func (t *limitedTransport) hostLimit(host string) *hostLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.hosts[host]
+	if h == nil {
+		h = &hostLimit{}
+		if t.hostConcurrency > 0 {
+			h.sem = make(chan struct{}, t.hostConcurrency)
+		}
+		if t.hosts == nil {
+			t.hosts = make(map[string]*hostLimit)
+		}
+		t.hosts[host] = h
+	}
+	return h
+}
+
+// acquire blocks until a request to a host is allowed, and returns a func to
+// call once the request is done.
+func (t *limitedTransport) acquire(ctx context.Context, h *hostLimit) (release func(), err error) {
+	release = func() {}
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-h.sem }
+	}
+	t.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(t.hostInterval)
+	t.mu.Unlock()
+	if err := sleepContext(ctx, at.Sub(now)); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// delay makes any further requests to a host wait for at least d,
+// such as when the host asks us to slow down via Retry-After.
+func (t *limitedTransport) delay(h *hostLimit, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at := time.Now().Add(d); at.After(h.next) {
+		h.next = at
+	}
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.hostLimit(req.URL.Host)
+	if len(t.headers) > 0 {
+		// A RoundTripper must not modify the request.
+		req = req.Clone(req.Context())
+		for _, hh := range t.headers {
+			if hh.host.MatchString(req.URL.Hostname()) {
+				maps.Copy(req.Header, hh.header)
+			}
+		}
+	}
+	wait := t.retryWait
+	for attempt := 0; ; attempt++ {
+		release, err := t.acquire(req.Context(), h)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithoutCancel(req.Context()), context.CancelFunc(func() {})
+		if t.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		}
+		done := func() {
+			cancel()
+			release()
+		}
+		resp, err := t.base.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			done()
+			return nil, err
+		}
+		retryAfter, retry := t.shouldRetry(resp, attempt, wait)
+		if !retry {
+			// We're done with the request once the body is closed.
+			resp.Body = &doneBody{ReadCloser: resp.Body, done: done}
+			return resp, nil
+		}
+		resp.Body.Close()
+		done()
+		t.delay(h, retryAfter)
+		wait *= 2
+	}
+}
+
+// shouldRetry reports whether a response asks us to retry a request,
+// and how long to wait before doing so. The wait is the response's
+// Retry-After header if present, or the exponential backoff wait otherwise.
+func (t *limitedTransport) shouldRetry(resp *http.Response, attempt int, wait time.Duration) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return 0, false
+	}
+	if attempt >= t.retries {
+		return 0, false
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		wait = d
+	}
+	if wait > t.maxWait {
+		// The server wants us to wait for too long; give up.
+		return 0, false
+	}
+	return wait, true
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+type doneBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// sleepContext sleeps for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transport is used by all link checking requests; see limitedTransport.
+var transport http.RoundTripper = http.DefaultTransport
@@ -6,27 +6,70 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync/atomic"
+	"text/template"
 	"time"
 
+	"github.com/rogpeppe/go-internal/diff"
+
 	"mvdan.cc/xurls/v2"
 )
 
 var (
-	matching    = flag.String("m", "", "")
-	relaxed     = flag.Bool("r", false, "")
-	fix         boolString
-	versionFlag = flag.Bool("version", false, "")
+	matching       = flag.String("m", "", "")
+	relaxed        = flag.Bool("r", false, "")
+	fix            boolString
+	check          boolString
+	statsFlag      boolString
+	unique         = flag.Bool("u", false, "")
+	countURLs      = flag.Bool("count", false, "")
+	normalize      = flag.Bool("normalize", false, "")
+	format         = flag.String("format", "", "")
+	nullSep        = flag.Bool("0", false, "")
+	statsTop       = flag.Int("top", 10, "")
+	reportFormat   = flag.String("report", "", "")
+	reportFile     = flag.String("report-file", "", "")
+	cacheDir       = flag.String("cache-dir", "", "")
+	cacheTTL       = flag.Duration("cache-ttl", 24*time.Hour, "")
+	cacheBroken    = flag.Duration("cache-ttl-broken", time.Hour, "")
+	cacheRefresh   = flag.Bool("cache-refresh", false, "")
+	jobs           = flag.Int("j", 32, "")
+	hostJobs       = flag.Int("host-j", 0, "")
+	hostRate       = flag.Float64("host-rate", 0, "")
+	retries        = flag.Int("retries", 2, "")
+	retryWait      = flag.Duration("retry-wait", time.Second, "")
+	configFile     = flag.String("config", "", "")
+	timeout        = flag.Duration("timeout", 10*time.Second, "")
+	maxRedirects   = flag.Int("max-redirects", 10, "")
+	userAgent      = flag.String("user-agent", "", "")
+	proxy          = flag.String("proxy", "", "")
+	headers        headerList
+	getOn          = statusList{400, 403, 404, 405, 501}
+	fragments      = flag.Bool("fragments", false, "")
+	backupSuffix   = flag.String("backup", "", "")
+	ignoreFlag     = flag.String("ignore", "", "")
+	baselineFile   = flag.String("baseline", "", "")
+	updateBaseline = flag.Bool("update-baseline", false, "")
+	deadMapFile    = flag.String("dead-map", "", "")
+	archiveURL     = flag.String("archive", "", "")
+	deadMarker     = flag.String("dead-marker", "", "")
+	progressFlag   = flag.Bool("progress", false, "")
+	diffFlag       = flag.Bool("d", false, "")
+	listFlag       = flag.Bool("l", false, "")
+	versionFlag    = flag.Bool("version", false, "")
 )
 
 type boolString string
@@ -41,6 +84,16 @@ func (*boolString) IsBoolFlag() bool { return true }
 
 func init() {
 	flag.Var(&fix, "fix", "")
+	flag.Var(&check, "check", "")
+	flag.Var(&statsFlag, "stats", "")
+	flag.Var(&resolve, "resolve", "")
+	flag.Var(&includeHosts, "host", "")
+	flag.Var(&excludeHosts, "exclude-host", "")
+	flag.Var(&includeURLs, "include", "")
+	flag.Var(&excludeURLs, "exclude", "")
+	flag.Var(&headers, "header", "")
+	flag.Var(&getOn, "get-on", "")
+	flag.BoolVar(diffFlag, "diff", false, "")
 	flag.Usage = func() {
 		fmt.Fprint(os.Stderr, `
 Usage: xurls [flags] [files]
@@ -50,119 +103,408 @@ If no files are given, it reads from standard input.
 
    -m <regexp>   only match urls whose scheme matches a regexp
                     example: 'https?://|mailto:'
-   -r            also match urls without a scheme (relaxed)
+   -r            also match urls without a scheme (relaxed);
+                    with -m, urls with a scheme must still match the regexp
+   -host <pattern>
+                 only match urls whose host matches a pattern
+   -exclude-host <pattern>
+                 skip urls whose host matches a pattern
+   -include <pattern>
+                 only match urls which match a pattern
+   -exclude <pattern>
+                 skip urls which match a pattern
+   -u            only print the first occurrence of each url
+   -count        print each url once with its number of occurrences,
+                    most frequent first
+   -normalize    treat equivalent urls as equal with -u and -count,
+                    such as 'http://Example.com' and 'http://example.com/'
+   -format <template>
+                 print each url with a text/template, such as
+                    '{{.File}}:{{.Line}}:{{.Column}}: {{.Host}} {{.URL}}'
+   -0            separate printed urls and file names with null bytes instead
+                    of newlines
+   -stats        print a summary of the urls by scheme, host, domain, file
+                    and kind instead of the urls; use -stats=json for JSON
+   -top <n>      only show the n most common entries with -stats (default 10,
+                    0 shows all)
    -version      print version and exit
 
 When the -fix or -fix=auto flag is used, xurls instead attempts to replace
 any urls which result in a permanent redirect (301 or 308).
 It also fails if any urls fail to load, so that they may be removed or replaced.
+Each broken url is reported as "file:line:column: url - reason".
 To replace urls which result in temporary redirect as well, use -fix=all.
+
+With -fix=https, xurls also replaces http urls with their https version, as
+long as it loads fine and ends up at the same url, ignoring the scheme.
+Any http urls without a working https version are reported as warnings,
+which don't cause a failure.
+
+With -fix=dead, xurls also replaces dead urls, which fail with 404 Not Found
+or 410 Gone, or whose host doesn't exist. A dead url is replaced with the url
+it maps to in the file given via -dead-map=<path>, which has a dead url and
+its replacement per line. Otherwise, with -archive=<url>, it is replaced with
+its closest archived version found via a lookup endpoint like the Wayback
+Machine's, such as -archive=https://archive.org/wayback/available. Otherwise,
+-dead-marker=<text> is added after the url, such as -dead-marker=' (dead link)'.
+Each replaced url is reported, and dead urls which can't be replaced are
+reported as failures.
+
+Modes may be combined, such as -fix=all,https.
+
+Files are replaced atomically, keeping their mode and owner. If the owner
+can't be kept, such as for files owned by another user, or if the directory
+isn't writable, they are written in place instead. Symlinks are kept,
+replacing the files they point to. Files which changed since xurls read them
+are not replaced. -backup=<suffix> keeps a copy of each replaced file, such
+as -backup=.orig.
+
+To review the changes before making them, -d or -diff prints a diff of the
+changes to each file, and -l prints the names of the files which would change.
+Neither modifies any files, and both imply -fix if it isn't given.
+
+When the -check flag is used, xurls loads urls just like with -fix, but it
+never modifies any files. Instead, it reports any broken urls, and exits with
+status code 3 if any were found. Use -check=auto to also report urls which
+result in a permanent redirect, or -check=all to report any redirect.
+Use -check=https to report http urls which could be upgraded to https,
+as well as http urls without a working https version.
+
+With -fix or -check, -report=<format> also writes a report of the broken urls
+to standard output, or to a file with -report-file=<path>, which is required
+with -fix. The supported formats are "json", "sarif", "junit" and "github" for
+GitHub Actions annotations.
+
+With -fix, -check or -resolve, urls are skipped entirely if they match any
+pattern in the file given via -ignore=<path>. The file has a pattern per line,
+like the ones for -include, or like the ones for -host if prefixed by "host:".
+Empty lines and lines starting with "#" are skipped. For example:
+
+   # examples which only work locally
+   host:localhost
+   host:*.internal
+   https://example.com/private/*
+
+Urls can also be skipped via directives in the input: "xurls:ignore" skips the
+urls in the same line, and "xurls:ignore-next-line" skips the urls in the
+next line. Directives are usually placed in comments.
+
+With -fix or -check, -baseline=<path> skips reporting the broken urls listed
+in a file, so that only new broken urls cause a failure. Each line in the file
+is a file path followed by a space and a url. -update-baseline replaces the
+entries for the files being checked with the broken urls found in them,
+creating the file if needed. Baseline entries for urls which are no longer
+broken are reported, so that they may be removed.
+
+With -fix or -check, -fragments also checks that url fragments such as
+"#installation" point to an existing anchor in the HTML page, loading the
+entire page. Anchors are "id" and "name" attributes, as well as the anchors
+generated for headings by GitHub. Urls with missing fragments are broken, and
+the closest existing anchor is suggested, if any.
+
+With -fix or -check, -cache-dir=<dir> stores the result of loading each url
+in a directory, and reuses results in later runs. Results are reused for
+-cache-ttl if the url loaded fine (default 24h), or for -cache-ttl-broken if
+the url was broken (default 1h). Results are not reused if the settings for
+loading urls changed, such as the headers, -proxy, -timeout, -user-agent or
+the TLS certificates. Use -cache-refresh to ignore cached results, while still
+storing the new ones.
+
+With -fix or -check, -progress prints how many urls were checked so far to
+standard error, as well as how many were broken or redirected. If xurls is
+interrupted, it waits for any requests in flight, prints what it found so
+far, and exits without modifying the file it was checking.
+
+When the -resolve flag is used, xurls follows all redirects for each http or
+https url, and prints the chain of redirects with their status codes, ending
+with the final status code or error, such as:
+
+   http://a.example -301-> https://a.example -302-> https://b.example 200
+
+Redirect loops are detected and reported. Use -resolve=short to only resolve
+urls from well known url shorteners, such as bit.ly. No files are modified.
+
+Urls are loaded concurrently, up to -j=<n> at a time (default 32).
+To avoid being rate limited by servers, -host-j=<n> limits the number of
+concurrent requests to each host, and -host-rate=<n> limits the number of
+requests per second to each host; both are unlimited by default.
+Requests which fail with 429 Too Many Requests or 503 Service Unavailable are
+retried up to -retries=<n> times (default 2), honoring any Retry-After header,
+or else waiting for -retry-wait=<duration> (default 1s) and doubling the wait
+with each retry.
+
+Requests time out after -timeout=<duration> (default 10s), and follow up to
+-max-redirects=<n> redirects (default 10; 0 doesn't follow any). They are sent
+with a User-Agent which can be replaced with -user-agent=<string>, and any
+number of extra headers can be added with -header='Name: value'. -proxy=<url>
+sends all requests through a proxy, instead of using the HTTP_PROXY and
+HTTPS_PROXY environment variables. These settings, as well as extra headers
+for specific hosts and TLS certificates, may also be given in a JSON file
+with -config=<path>:
+
+   {
+      "timeout": "30s",
+      "maxRedirects": 5,
+      "userAgent": "my-link-checker",
+      "proxy": "http://proxy.internal:3128",
+      "caFile": "corporate-ca.pem",
+      "certFile": "client.pem",
+      "keyFile": "client-key.pem",
+      "headers": {
+         "*.docs.internal": {"Authorization": "Bearer ${DOCS_TOKEN}"}
+      },
+      "policies": {
+         "*linkedin.com": {"accept": [999]},
+         "api.internal": {"accept": [401, 403]},
+         "twitter.com": {"action": "skip"}
+      }
+   }
+
+Urls are loaded with HEAD requests, as their content isn't needed. Since many
+servers reject HEAD requests, a response with a status code in
+-get-on=<codes> (default 400,403,404,405,501) is retried with a GET request
+asking for a single byte of content. Use -get-on= to never retry with GET.
+This list may also be given in the configuration file as "getOn": [403, 405].
+
+The "policies" in the configuration file change how the urls from some hosts
+are treated, since some hosts always reject bots, and some endpoints require
+authentication. "accept" lists status codes which don't make a url broken.
+"action" may be "ok" to never report urls as broken, "skip" to not load urls
+at all, or "warn" to report broken urls as warnings which don't cause a
+failure. If several host patterns match, the longest one is used.
+
+Flags given on the command line take precedence over the configuration file.
+Header values may use environment variables, and hosts are patterns like in
+-host. The CA certificates are trusted in addition to the system's.
+
+The -host, -exclude-host, -include and -exclude flags may be repeated.
+A pattern is a glob matching the entire host or url, such as '*.example.com',
+where '*' matches any characters. A pattern surrounded by slashes, such as
+'/^https?:/', is a regular expression which may match anywhere.
+All patterns are case-insensitive.
+
+The -format template is executed with each url's fields URL, Scheme, Host,
+Port, Path, Query and Fragment; its position via File, Line and Column;
+and Kind, which is one of "url", "domain" or "email". With -count, Count
+is the number of occurrences. With -fix or -check, Status is the result of
+loading the url, Method is the HTTP method which gave that result, and
+Redirect is the url it redirects to, if any; the urls are then printed
+instead of the fixed standard input or file names.
 `[1:])
 	}
 }
 
-func scanPath(re *regexp.Regexp, path string) error {
+// redirects is "auto" or "all", depending on which redirects are followed
+// with -fix or -check. See checkURL.
+var redirects = "auto"
+
+// findingsError is returned by scanPath when -check finds broken or
+// redirected urls in a file, so that main can continue with the other files.
+type findingsError struct {
+	report string
+}
+
+func (e *findingsError) Error() string { return e.report }
+
+// warningsError is returned by scanPath when the only findings don't cause a
+// failure, such as warnings from "warn" host policies, the dead urls which
+// were replaced with -fix=dead, or the http urls which -fix=https can't
+// upgrade.
+type warningsError struct {
+	report string
+}
+
+func (e *warningsError) Error() string { return e.report }
+
+func scanPath(ctx context.Context, re *regexp.Regexp, path string) error {
 	in := os.Stdin
 	out := io.Writer(os.Stdout)
-	var outBuf *bytes.Buffer
+	if formatTmpl != nil || check != "" {
+		// The fixed input is replaced by the printed urls,
+		// or not printed at all with -check.
+		out = io.Discard
+	}
+	dryRun := *diffFlag || *listFlag
 	if path != "-" {
 		var err error
 		in, err = os.Open(path)
 		if err != nil {
 			return err
 		}
-		if fix != "" {
-			outBuf = new(bytes.Buffer)
-			out = outBuf
-		}
 		defer in.Close()
 	}
+	src := io.Reader(in)
+	var outBuf, origBuf *bytes.Buffer
+	var inHash hash.Hash
+	if fix != "" {
+		// Buffered, so that nothing is written if we are interrupted.
+		outBuf = new(bytes.Buffer)
+		out = outBuf
+		// Remember what we read, to not overwrite any later changes.
+		inHash = sha256.New()
+		src = io.TeeReader(src, inHash)
+		if *diffFlag {
+			origBuf = new(bytes.Buffer)
+			src = io.TeeReader(src, origBuf)
+		}
+	}
 
-	// A maximum of 32 parallel requests.
-	maxWeight := int64(32)
-	seq := newSequencer(maxWeight, out, os.Stderr)
+	// A maximum of -j parallel requests.
+	maxWeight := int64(*jobs)
+	seq := newSequencer(ctx, maxWeight, out, os.Stderr)
 
-	userAgent := fmt.Sprintf("mvdan.cc/xurls %s", readVersion())
-	scanner := bufio.NewScanner(in)
+	// Lines are read with their line endings, if any, so that -fix only
+	// modifies the urls which it replaces.
+	reader := bufio.NewReader(src)
 
 	// Doesn't need to be part of reporterState as order doesn't matter.
 	var fixedCount atomic.Uint32
 
-	for scanner.Scan() {
-		line := scanner.Text() + "\n"
+	// Set by an "xurls:ignore-next-line" directive.
+	ignoringLine := false
+
+	for lineNum := 1; ; lineNum++ {
+		if ctx.Err() != nil {
+			break // interrupted; stop adding tasks
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			break // io.EOF
+		}
+		if lineNum == 1 {
+			// A UTF-8 byte order mark isn't part of the first line's columns.
+			if rest, ok := strings.CutPrefix(line, "\uFEFF"); ok {
+				line = rest
+				if fix != "" {
+					io.WriteString(out, "\uFEFF") // no tasks were added yet
+				}
+			}
+		}
 		matches := re.FindAllStringIndex(line, -1)
-		if fix == "" {
+		matches = slices.DeleteFunc(matches, func(pair []int) bool {
+			return !filterAllows(line[pair[0]:pair[1]])
+		})
+		if resolve != "" {
+			matches = slices.DeleteFunc(matches, func(pair []int) bool {
+				return !resolvable(line[pair[0]:pair[1]])
+			})
+		} else if fix == "" && check == "" {
 			for _, pair := range matches {
-				match := line[pair[0]:pair[1]]
-				fmt.Printf("%s\n", match)
+				printMatch(newMatchInfo(path, lineNum, pair[0]+1, line[pair[0]:pair[1]]))
 			}
 			continue
 		}
+		ignoreThis, ignoreNext := ignoreDirectives(line)
+		if ignoreThis || ignoringLine {
+			matches = nil
+		} else {
+			matches = slices.DeleteFunc(matches, func(pair []int) bool {
+				return ignores.ignored(line[pair[0]:pair[1]])
+			})
+		}
+		ignoringLine = ignoreNext
 		weight := min(int64(len(matches)), maxWeight)
+		prog.found.Add(int64(len(matches)))
 		seq.Add(weight, func(r *reporter) error {
-			offsetWithinLine := 0
-			for _, pair := range matches {
-				// The indexes are based on the original line.
-				pair[0] += offsetWithinLine
-				pair[1] += offsetWithinLine
-				match := line[pair[0]:pair[1]]
-				origURL, err := url.Parse(match)
-				if err != nil {
-					r.appendBroken(match, err.Error())
-					continue
+			// Load all the urls before using the reporter,
+			// as it blocks until the previous lines are done.
+			results := make([]checkResult, len(matches))
+			for i, pair := range matches {
+				results[i] = checkMatch(ctx, line[pair[0]:pair[1]])
+				if !results[i].canceled {
+					prog.checked.Add(1)
 				}
-				fixed := origURL.String()
-				switch origURL.Scheme {
-				case "http", "https":
-					// See if the URL redirects somewhere.
-					client := &http.Client{
-						Timeout: 10 * time.Second,
-						CheckRedirect: func(req *http.Request, via []*http.Request) error {
-							if len(via) >= 10 {
-								return errors.New("stopped after 10 redirects")
-							}
-							switch req.Response.StatusCode {
-							case http.StatusMovedPermanently, http.StatusPermanentRedirect:
-								// "auto" and "all" fix permanent redirects.
-							case http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect:
-								// Only "all" fixes temporary redirects.
-								if fix != "all" {
-									return http.ErrUseLastResponse
-								}
-							default:
-								// Any other redirects are ignored.
-								return http.ErrUseLastResponse
-							}
-							// Inherit the fragment if empty.
-							if req.URL.Fragment == "" {
-								req.URL.Fragment = origURL.Fragment
-							}
-							fixed = req.URL.String()
-							return nil
-						},
+			}
+			var deadFixes []string
+			if fixDead {
+				deadFixes = make([]string, len(matches))
+				for i, pair := range matches {
+					if res := results[i]; res.broken && res.dead {
+						deadFixes[i] = fixDeadURL(ctx, line[pair[0]:pair[1]], line[pair[1]:])
 					}
-					method := http.MethodHead
-				retry:
-					req, err := http.NewRequest(method, fixed, nil)
-					if err != nil {
-						r.appendBroken(match, err.Error())
+				}
+			}
+			if resolve != "" {
+				for i, pair := range matches {
+					res := results[i]
+					if res.canceled {
 						continue
 					}
-					req.Header.Set("User-Agent", userAgent)
-					resp, err := client.Do(req)
-					if err != nil {
-						r.appendBroken(match, err.Error())
-						continue
+					match := line[pair[0]:pair[1]]
+					info := newMatchInfo(path, lineNum, pair[0]+1, match)
+					info.Status = res.status
+					info.Method = res.method
+					if len(res.redirects) > 0 {
+						info.Redirect = res.fixed
 					}
-					if code := resp.StatusCode; code >= 400 {
-						if code == http.StatusMethodNotAllowed {
-							method = http.MethodGet
-							resp.Body.Close()
-							goto retry
-						}
-						r.appendBroken(match, fmt.Sprintf("%d %s", code, http.StatusText(code)))
+					r.appendMatch(info)
+					fmt.Fprintln(r, resolvedChain(match, res))
+				}
+				return nil
+			}
+			offsetWithinLine := 0
+			for i, pair := range matches {
+				res := results[i]
+				if res.canceled {
+					continue // interrupted before the url was loaded
+				}
+				col := pair[0] + 1
+				// The indexes are based on the original line.
+				pair[0] += offsetWithinLine
+				pair[1] += offsetWithinLine
+				match := line[pair[0]:pair[1]]
+				info := newMatchInfo(path, lineNum, col, match)
+				info.Status = res.status
+				info.Method = res.method
+				fixed := rewriteURL(match, res.fixed)
+				if deadFixes != nil && deadFixes[i] != "" {
+					fixed = deadFixes[i]
+					res.broken = false
+					if fixed != match {
+						r.appendFinding(finding{
+							kind:   replacedFinding,
+							url:    match,
+							line:   lineNum,
+							col:    col,
+							target: fixed,
+						})
 					}
-					resp.Body.Close()
+				}
+				if res.broken {
+					prog.broken.Add(1)
+					r.appendFinding(finding{
+						kind:      brokenFinding,
+						url:       match,
+						line:      lineNum,
+						col:       col,
+						reason:    res.status,
+						code:      res.code,
+						method:    res.method,
+						redirects: res.redirects,
+					})
+				}
+				if res.warning != "" {
+					r.appendFinding(finding{
+						kind:   warningFinding,
+						url:    match,
+						line:   lineNum,
+						col:    col,
+						reason: res.warning,
+						code:   res.code,
+						method: res.method,
+					})
+				}
+				if res.insecure != "" {
+					r.appendFinding(finding{
+						kind:   insecureFinding,
+						url:    match,
+						line:   lineNum,
+						col:    col,
+						reason: res.insecure,
+					})
 				}
 				if fixed != match {
 					// Replace the url, and update offsetWithinLine.
@@ -170,38 +512,156 @@ func scanPath(re *regexp.Regexp, path string) error {
 					offsetWithinLine += len(newLine) - len(line)
 					line = newLine
 					fixedCount.Add(1)
+					prog.redirected.Add(1)
+					info.Redirect = fixed
+					if check == "auto" || check == "all" || res.upgraded {
+						r.appendFinding(finding{
+							kind:   redirectFinding,
+							url:    match,
+							line:   lineNum,
+							col:    col,
+							target: fixed,
+						})
+					}
 				}
+				r.appendMatch(info)
 			}
 			io.WriteString(r, line) // add the fixed line to outBuf
 			return nil
 		})
-		if err := scanner.Err(); err != nil {
-			return err
-		}
 	}
 	state := seq.finalState()
 	if state.exitCode != 0 {
 		panic("we aren't using sequencer for any errors")
 	}
 	// Note that all goroutines have stopped at this point.
-	if fixedCount.Load() > 0 && path != "-" {
+	if knownBroken != nil && ctx.Err() == nil {
+		state.findings = knownBroken.filter(path, state.findings)
+	}
+	if *reportFormat != "" {
+		fileReports = append(fileReports, fileReport{path, state.matches, state.ofKind(brokenFinding)})
+	}
+	if formatTmpl != nil {
+		for _, info := range state.matches {
+			writeMatch(os.Stdout, info)
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		// Interrupted, so the output is incomplete; don't write it.
+	case fixedCount.Load() > 0 && dryRun:
+		name := path
+		if path == "-" {
+			name = "<standard input>"
+		}
+		if *listFlag {
+			fmt.Print(name, separator())
+		}
+		if *diffFlag {
+			os.Stdout.Write(diff.Diff(name+".orig", origBuf.Bytes(), name, outBuf.Bytes()))
+		}
+	case path == "-" && fix != "" && !dryRun:
+		if formatTmpl == nil {
+			os.Stdout.Write(outBuf.Bytes())
+		}
+	case fixedCount.Load() > 0 && check == "":
 		in.Close()
 		// Overwrite the file, if we weren't reading stdin. Report its
-		// path too.
-		fmt.Println(path)
-		if err := os.WriteFile(path, outBuf.Bytes(), 0o666); err != nil {
+		// path too once written, unless we are printing the urls with -format.
+		if err := writeFixed(path, outBuf.Bytes(), inHash.Sum(nil)); err != nil {
 			return err
 		}
+		if formatTmpl == nil {
+			fmt.Print(path, separator())
+		}
 	}
-	if len(state.brokenURLs) > 0 {
-		var s strings.Builder
-		fmt.Fprintf(&s, "found %d broken urls in %q:\n", len(state.brokenURLs), path)
-		for _, broken := range state.brokenURLs {
-			fmt.Fprintf(&s, "  * %s - %s\n", broken.url, broken.reason)
+	// The findings in w don't cause a failure; see warningsError.
+	var w, s strings.Builder
+	writeFindings(&w, path, state.ofKind(warningFinding))
+	writeFindings(&w, path, state.ofKind(replacedFinding))
+	writeFindings(&s, path, state.ofKind(brokenFinding))
+	if check == "" {
+		// With -fix, http urls without a working https version are only
+		// warnings, so that the other files are still fixed.
+		writeFindings(&w, path, state.ofKind(insecureFinding))
+		if s.Len() > 0 {
+			return errors.New(w.String() + s.String())
 		}
-		return errors.New(s.String())
+		return warnings(w.String())
 	}
-	return nil
+	writeFindings(&s, path, state.ofKind(insecureFinding))
+	writeFindings(&s, path, state.ofKind(redirectFinding))
+	if s.Len() > 0 {
+		return &findingsError{w.String() + s.String()}
+	}
+	return warnings(w.String())
+}
+
+// findingHeaders are the lines which precede each kind of finding for a file,
+// given the number of findings and the file path.
+var findingHeaders = [...]string{
+	brokenFinding:   "found %d broken urls in %q:\n",
+	insecureFinding: "found %d urls without a working https version in %q:\n",
+	warningFinding:  "found %d urls with warnings in %q:\n",
+	redirectFinding: "found %d redirected urls in %q:\n",
+	replacedFinding: "replaced %d dead urls in %q:\n",
+}
+
+// writeFindings writes findings of one kind in a file, if any, as
+// "file:line:column: url - reason", or "file:line:column: url -> target".
+func writeFindings(w *strings.Builder, path string, findings []finding) {
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintf(w, findingHeaders[findings[0].kind], len(findings), path)
+	for _, f := range findings {
+		if f.target != "" {
+			fmt.Fprintf(w, "%s:%d:%d: %s -> %s\n", path, f.line, f.col, f.url, f.target)
+		} else {
+			fmt.Fprintf(w, "%s:%d:%d: %s - %s\n", path, f.line, f.col, f.url, f.reason)
+		}
+	}
+}
+
+// warnings returns a *warningsError for a report of warnings, if any.
+func warnings(report string) error {
+	if report == "" {
+		return nil
+	}
+	return &warningsError{report}
+}
+
+// upgradeHTTPS is set by -fix=https or -check=https. See upgradeToHTTPS.
+var upgradeHTTPS bool
+
+// parseModes parses the comma-separated modes given to -fix or -check,
+// such as "all,https", and replaces them with the mode for redirects,
+// which is "auto" or "all", or def if neither was given.
+// It reports whether all modes were valid.
+func parseModes(modes *boolString, def string) bool {
+	mode := ""
+	for m := range strings.SplitSeq(string(*modes), ",") {
+		switch m {
+		case "true":
+		case "auto":
+			if mode == "" {
+				mode = m
+			}
+		case "all":
+			mode = m
+		case "https":
+			upgradeHTTPS = true
+		case "dead":
+			fixDead = true
+		default:
+			return false
+		}
+	}
+	if mode == "" {
+		mode = def
+	}
+	*modes = boolString(mode)
+	return true
 }
 
 func main() {
@@ -210,43 +670,332 @@ func main() {
 		fmt.Println(readVersion())
 		return
 	}
-	if *relaxed && *matching != "" {
-		fmt.Fprintln(os.Stderr, "-r and -m at the same time don't make much sense")
-		os.Exit(1)
-	}
 	switch fix {
 	case "": // disabled by default
 	case "false": // disabled via -fix=false; normalize
 		fix = ""
-	case "auto", "all": // enabled via -fix=auto, -fix=all, etc
-	case "true": // enabled via -fix; normalize
+	default: // enabled via -fix, -fix=all, -fix=all,https, etc
+		if !parseModes(&fix, "auto") {
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	if (*diffFlag || *listFlag) && fix == "" && check == "" {
 		fix = "auto"
+	}
+	switch check {
+	case "": // disabled by default
+	case "false": // disabled via -check=false; normalize
+		check = ""
+	default: // enabled via -check, -check=auto, etc; by default, only report broken urls
+		if !parseModes(&check, "broken") {
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	switch resolve {
+	case "": // disabled by default
+	case "false": // disabled via -resolve=false; normalize
+		resolve = ""
+	case "true": // enabled via -resolve; normalize
+		resolve = "all"
+	case "all", "short":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if fixDead && check != "" {
+		fmt.Fprintln(os.Stderr, "dead urls can only be replaced with -fix=dead")
+		os.Exit(1)
+	}
+	if fix != "" && check != "" {
+		fmt.Fprintln(os.Stderr, "-fix and -check cannot be used at the same time")
+		os.Exit(1)
+	}
+	if resolve != "" && (fix != "" || check != "") {
+		fmt.Fprintln(os.Stderr, "-resolve cannot be used with -fix or -check")
+		os.Exit(1)
+	}
+	if fix == "all" || check == "all" || resolve != "" {
+		redirects = "all"
+	}
+	switch statsFlag {
+	case "": // disabled by default
+	case "false": // disabled via -stats=false; normalize
+		statsFlag = ""
+	case "true": // enabled via -stats; normalize
+		statsFlag = "text"
+	case "text", "json":
 	default:
 		flag.Usage()
 		os.Exit(2)
 	}
+	if (fix != "" || check != "" || resolve != "") && (*unique || *countURLs || statsFlag != "") {
+		fmt.Fprintln(os.Stderr, "-u, -count and -stats cannot be used with -fix, -check or -resolve")
+		os.Exit(1)
+	}
+	if *reportFormat != "" {
+		if reportFormats[*reportFormat] == nil {
+			fmt.Fprintf(os.Stderr, "unknown -report format: %q\n", *reportFormat)
+			os.Exit(2)
+		}
+		if fix == "" && check == "" {
+			fmt.Fprintln(os.Stderr, "-report requires -fix or -check")
+			os.Exit(1)
+		}
+		if fix != "" && *reportFile == "" {
+			// Standard output is used for the fixed input and file names.
+			fmt.Fprintln(os.Stderr, "-report with -fix requires -report-file")
+			os.Exit(1)
+		}
+	}
+	if *fragments && fix == "" && check == "" {
+		fmt.Fprintln(os.Stderr, "-fragments requires -fix or -check")
+		os.Exit(1)
+	}
+	if (*diffFlag || *listFlag) && (check != "" || *format != "") {
+		fmt.Fprintln(os.Stderr, "-d and -l cannot be used with -check or -format")
+		os.Exit(1)
+	}
+	if *progressFlag && fix == "" && check == "" && resolve == "" {
+		fmt.Fprintln(os.Stderr, "-progress requires -fix, -check or -resolve")
+		os.Exit(1)
+	}
+	if *backupSuffix != "" && fix == "" {
+		fmt.Fprintln(os.Stderr, "-backup requires -fix")
+		os.Exit(1)
+	}
+	if *baselineFile != "" && fix == "" && check == "" {
+		fmt.Fprintln(os.Stderr, "-baseline requires -fix or -check")
+		os.Exit(1)
+	}
+	if *updateBaseline && *baselineFile == "" {
+		fmt.Fprintln(os.Stderr, "-update-baseline requires -baseline")
+		os.Exit(1)
+	}
+	if fixDead && *deadMapFile == "" && *archiveURL == "" && *deadMarker == "" {
+		fmt.Fprintln(os.Stderr, "-fix=dead requires -dead-map, -archive or -dead-marker")
+		os.Exit(1)
+	}
+	if !fixDead && (*deadMapFile != "" || *archiveURL != "" || *deadMarker != "") {
+		fmt.Fprintln(os.Stderr, "-dead-map, -archive and -dead-marker require -fix=dead")
+		os.Exit(1)
+	}
+	if *jobs < 1 {
+		fmt.Fprintln(os.Stderr, "-j must be at least 1")
+		os.Exit(2)
+	}
+	if *ignoreFlag != "" {
+		var err error
+		if ignores, err = loadIgnoreFile(*ignoreFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *deadMapFile != "" {
+		var err error
+		if deadMap, err = loadDeadMap(*deadMapFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *baselineFile != "" {
+		var err error
+		if knownBroken, err = loadBaseline(*baselineFile, *updateBaseline); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if err := setupHTTP(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *cacheDir != "" {
+		if err := os.MkdirAll(*cacheDir, 0o777); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		cache = &linkCache{
+			dir:       *cacheDir,
+			ttl:       *cacheTTL,
+			brokenTTL: *cacheBroken,
+			refresh:   *cacheRefresh,
+		}
+	}
+	if statsFlag != "" && (*countURLs || *format != "") {
+		fmt.Fprintln(os.Stderr, "-stats cannot be used with -count or -format")
+		os.Exit(1)
+	}
+	if *format != "" {
+		var err error
+		if formatTmpl, err = template.New("format").Parse(*format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 	var re *regexp.Regexp
-	if *relaxed {
+	switch {
+	case *relaxed && *matching != "":
+		var err error
+		if re, err = xurls.RelaxedMatchingScheme(*matching); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	case *relaxed:
 		re = xurls.Relaxed()
-	} else if *matching != "" {
+	case *matching != "":
 		var err error
 		if re, err = xurls.StrictMatchingScheme(*matching); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(2)
 		}
-	} else {
+	default:
 		re = xurls.Strict()
 	}
 	args := flag.Args()
 	if len(args) == 0 {
 		args = []string{"-"}
 	}
+	// With -fix or -check, an interrupt stops loading urls, and the findings
+	// so far are printed. A second interrupt stops right away.
+	var progress *progressPrinter
+	if *progressFlag {
+		progress = startProgress(os.Stderr)
+	}
+	ctx := context.Background()
+	if fix != "" || check != "" || resolve != "" {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		go func() {
+			<-ctx.Done()
+			stop()
+			// Slow requests may take a while, so say why we haven't stopped.
+			const msg = "interrupted; waiting for the requests in flight, interrupt again to stop right away\n"
+			if progress != nil {
+				progress.printf(msg)
+			} else {
+				fmt.Fprint(os.Stderr, msg)
+			}
+		}()
+	}
+	exitCode := 0
 	for _, path := range args {
-		if err := scanPath(re, path); err != nil {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := scanPath(ctx, re, path); err != nil {
+			if progress != nil {
+				progress.printf("%s\n", err)
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			switch err.(type) {
+			case *warningsError:
+				continue
+			case *findingsError:
+				exitCode = 3
+				continue
+			}
+			exitCode = 1
+			break
+		}
+	}
+	if progress != nil {
+		progress.stop()
+	}
+	writeReport()
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "interrupted; the file being checked was not modified")
+		os.Exit(130) // like shells do for SIGINT
+	}
+	if exitCode == 1 {
+		os.Exit(1)
+	}
+	if knownBroken != nil {
+		if *updateBaseline {
+			if err := knownBroken.write(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		} else if fixed := knownBroken.fixed(); len(fixed) > 0 {
+			fmt.Fprintf(os.Stderr, "found %d baseline entries which are no longer broken; remove them with -update-baseline:\n", len(fixed))
+			for _, entry := range fixed {
+				fmt.Fprintln(os.Stderr, entry)
+			}
+		}
+	}
+	if *countURLs {
+		printCounts()
+	}
+	if statsFlag != "" {
+		if err := stats.print(os.Stdout, *statsTop, statsFlag == "json"); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 	}
+	os.Exit(exitCode)
+}
+
+// httpConfig holds the settings for loading urls, from -config and the flags.
+var httpConfig = &config{}
+
+// setupHTTP loads the configuration file given via -config, if any,
+// applies the flags on top of it, and sets up the transport for loading urls.
+func setupHTTP() error {
+	if *configFile != "" {
+		cfg, err := loadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		httpConfig = cfg
+	}
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if setFlags["timeout"] || httpConfig.Timeout == 0 {
+		httpConfig.Timeout = duration(*timeout)
+	}
+	if setFlags["max-redirects"] || httpConfig.MaxRedirects == nil {
+		httpConfig.MaxRedirects = maxRedirects
+	}
+	if setFlags["user-agent"] {
+		httpConfig.UserAgent = *userAgent
+	}
+	if httpConfig.UserAgent == "" {
+		httpConfig.UserAgent = fmt.Sprintf("mvdan.cc/xurls %s", readVersion())
+	}
+	if setFlags["proxy"] {
+		httpConfig.Proxy = *proxy
+	}
+	if setFlags["get-on"] || httpConfig.GetOn == nil {
+		httpConfig.GetOn = getOn
+	}
+
+	base, err := newHTTPTransport(httpConfig)
+	if err != nil {
+		return err
+	}
+	hostHeaders, err := parseHeaders(httpConfig, headers)
+	if err != nil {
+		return err
+	}
+	if policies, err = parsePolicies(httpConfig); err != nil {
+		return err
+	}
+	cacheSettings = settingsHash(httpConfig, hostHeaders)
+	limited := &limitedTransport{
+		base:            base,
+		timeout:         time.Duration(httpConfig.Timeout),
+		headers:         hostHeaders,
+		hostConcurrency: *hostJobs,
+		retries:         *retries,
+		retryWait:       *retryWait,
+		maxWait:         time.Minute,
+	}
+	if *hostRate > 0 {
+		limited.hostInterval = time.Duration(float64(time.Second) / *hostRate)
+	}
+	transport = limited
+	return nil
 }
 
 func readVersion() string {
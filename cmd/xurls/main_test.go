@@ -4,13 +4,26 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rogpeppe/go-internal/testscript"
 )
@@ -72,12 +85,90 @@ func TestScript(t *testing.T) {
 				http.Redirect(w, r, "/plain-head", http.StatusPermanentRedirect)
 			})
 
-			handle("HEAD", "/404", func(w http.ResponseWriter, r *http.Request) {
+			mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "", 404)
 			})
 			handle("HEAD", "/500", func(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "", 500)
 			})
+			mux.HandleFunc("/410", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "", 410)
+			})
+			// /wayback/available is a stand-in for the Wayback Machine's
+			// availability API, which only has snapshots of urls with
+			// "archived" in them. The snapshots are served under /web/.
+			mux.HandleFunc("/wayback/available", func(w http.ResponseWriter, r *http.Request) {
+				page := r.URL.Query().Get("url")
+				if !strings.Contains(page, "archived") {
+					io.WriteString(w, `{"archived_snapshots": {}}`)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{
+					"archived_snapshots": map[string]any{
+						"closest": map[string]any{
+							"available": true,
+							"url":       "http://" + r.Host + "/web/20200101000000/" + page,
+							"timestamp": "20200101000000",
+							"status":    "200",
+						},
+					},
+				})
+			})
+			mux.HandleFunc("/web/", func(w http.ResponseWriter, r *http.Request) {})
+			handle("HEAD", "/redir-404", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/404", http.StatusMovedPermanently)
+			})
+
+			// /head-403 and /head-501 reject HEAD requests, like many servers
+			// do, and expect GET requests to ask for a range of bytes.
+			// /no-range rejects any request for a range, as its body is empty.
+			headRejected := func(code int) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					switch {
+					case r.Method == "HEAD":
+						http.Error(w, "", code)
+					case r.Header.Get("Range") != "bytes=0-0":
+						http.Error(w, "", http.StatusBadRequest)
+					default:
+						w.WriteHeader(http.StatusPartialContent)
+						io.WriteString(w, "x")
+					}
+				}
+			}
+			mux.HandleFunc("/head-403", headRejected(http.StatusForbidden))
+			mux.HandleFunc("/head-501", headRejected(http.StatusNotImplemented))
+			mux.HandleFunc("/no-range", func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "HEAD":
+					http.Error(w, "", http.StatusNotFound)
+				case r.Header.Get("Range") != "":
+					http.Error(w, "", http.StatusRequestedRangeNotSatisfiable)
+				}
+			})
+
+			mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				io.WriteString(w, `<!DOCTYPE html>
+<html><head><meta name="description" content="Docs"></head><body>
+<h1 id="user-content-overview">Overview</h1>
+<h2>Installation &amp; Setup</h2>
+<h2>Usage</h2>
+<h3>Usage</h3>
+<h4>Notes<style>h4 { color: red }</style></h4>
+<div class='x' id='configuration'>...</div>
+<a name=legacy-anchor></a>
+<!-- <a id="commented"></a> -->
+<script>var s = '<div id="ghost">';</script>
+</body></html>
+`)
+			})
+			mux.HandleFunc("/docs-moved", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/docs", http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, "no anchors here")
+			})
 
 			handle("GET", "/plain-get", func(w http.ResponseWriter, r *http.Request) {
 				fmt.Fprintf(w, "plaintext")
@@ -90,11 +181,202 @@ func TestScript(t *testing.T) {
 				}
 			})
 
+			// For /once, the first request for each query string succeeds,
+			// and any later requests for it fail. /fail-once is the opposite.
+			// GET requests, sent after a failed HEAD, get the same result.
+			var onceMu sync.Mutex
+			onceSeen := make(map[string]bool)
+			onceLast := make(map[string]int)
+			once := func(failFirst bool) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					onceMu.Lock()
+					defer onceMu.Unlock()
+					key := r.URL.Path + "?" + r.URL.RawQuery
+					if r.Method == "GET" {
+						w.WriteHeader(onceLast[key])
+						return
+					}
+					code := 404
+					if onceSeen[key] == failFirst {
+						code = 200
+					}
+					onceSeen[key] = true
+					onceLast[key] = code
+					w.WriteHeader(code)
+				}
+			}
+			mux.HandleFunc("/once", once(false))
+			mux.HandleFunc("/fail-once", once(true))
+
+			// For /rate-limited, the first two requests for each query string
+			// are told to slow down, the first with a Retry-After header.
+			var limitedMu sync.Mutex
+			limitedCount := make(map[string]int)
+			mux.HandleFunc("/rate-limited", func(w http.ResponseWriter, r *http.Request) {
+				limitedMu.Lock()
+				limitedCount[r.URL.RawQuery]++
+				n := limitedCount[r.URL.RawQuery]
+				limitedMu.Unlock()
+				switch n {
+				case 1:
+					w.Header().Set("Retry-After", "0")
+					http.Error(w, "", http.StatusTooManyRequests)
+				case 2:
+					http.Error(w, "", http.StatusServiceUnavailable)
+				}
+			})
+			// /serial fails if it's requested concurrently,
+			// and /spaced fails if requested twice within 50ms.
+			var serialMu, spacedMu sync.Mutex
+			serialBusy := false
+			var spacedLast time.Time
+			mux.HandleFunc("/serial", func(w http.ResponseWriter, r *http.Request) {
+				serialMu.Lock()
+				busy := serialBusy
+				serialBusy = true
+				serialMu.Unlock()
+				if busy {
+					http.Error(w, "", http.StatusConflict)
+					return
+				}
+				time.Sleep(20 * time.Millisecond)
+				serialMu.Lock()
+				serialBusy = false
+				serialMu.Unlock()
+			})
+			mux.HandleFunc("/spaced", func(w http.ResponseWriter, r *http.Request) {
+				spacedMu.Lock()
+				defer spacedMu.Unlock()
+				if time.Since(spacedLast) < 50*time.Millisecond {
+					http.Error(w, "", http.StatusConflict)
+				}
+				spacedLast = time.Now()
+			})
+
+			mux.HandleFunc("/need-header", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Token") != "secret" {
+					http.Error(w, "", http.StatusUnauthorized)
+				}
+			})
+			mux.HandleFunc("/need-user-agent", func(w http.ResponseWriter, r *http.Request) {
+				if r.UserAgent() != "custom/1.0" {
+					http.Error(w, "", http.StatusForbidden)
+				}
+			})
+			// /slow takes 200ms to respond.
+			mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			})
+			// /block?k=<key> doesn't respond until the script runs
+			// "unblock <key>", so that the script can do something while
+			// the request is in flight; see "waitblocked".
+			blocks := &blocker{keys: make(map[string]*blockedKey)}
+			env.Values[blockerKey{}] = blocks
+			mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+				key := blocks.get(r.URL.Query().Get("k"))
+				key.arriveOnce.Do(func() { close(key.arrived) })
+				select {
+				case <-key.release:
+				case <-r.Context().Done():
+				}
+			})
+
+			// /any/ accepts any path, and /moved/ redirects to it with
+			// canonical percent-encoding. /moved-abs/ redirects to a
+			// lowercase host, and old.example.com redirects to example.com.
+			mux.HandleFunc("/any/", func(w http.ResponseWriter, r *http.Request) {})
+			mux.HandleFunc("/moved/", func(w http.ResponseWriter, r *http.Request) {
+				target := "/any/" + url.PathEscape(strings.TrimPrefix(r.URL.Path, "/moved/"))
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("/moved-abs/", func(w http.ResponseWriter, r *http.Request) {
+				target := "http://example.com/any/" + strings.TrimPrefix(r.URL.Path, "/moved-abs/")
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("example.com/{$}", func(w http.ResponseWriter, r *http.Request) {})
+			mux.HandleFunc("old.example.com/", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://example.com/", http.StatusMovedPermanently)
+			})
+
+			// /loop-a and /loop-b redirect to each other.
+			mux.HandleFunc("/loop-a", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/loop-b", http.StatusFound)
+			})
+			mux.HandleFunc("/loop-b", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/loop-a", http.StatusMovedPermanently)
+			})
+			// Url shorteners, via the proxy.
+			mux.HandleFunc("bit.ly/", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://example.com/any"+r.URL.Path, http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("t.co/", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://bit.ly"+r.URL.Path, http.StatusFound)
+			})
+			// Hosts which need a policy, via the proxy.
+			mux.HandleFunc("www.linkedin.com/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(999) // what LinkedIn answers to bots
+			})
+			mux.HandleFunc("api.internal/", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "", http.StatusUnauthorized)
+			})
+			mux.HandleFunc("skipped.example.com/", func(w http.ResponseWriter, r *http.Request) {
+				panic("urls with a skip policy should not be loaded")
+			})
+
+			// /https-differs redirects elsewhere only when loaded via https.
+			mux.HandleFunc("/https-differs", func(w http.ResponseWriter, r *http.Request) {
+				if r.TLS != nil {
+					http.Redirect(w, r, "/plain-head", http.StatusMovedPermanently)
+				}
+			})
+
+			// A TLS server with its own CA certificate, which isn't trusted by
+			// default.
+			tlsServer := httptest.NewUnstartedServer(mux)
+			tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+			tlsServer.StartTLS()
+			env.Defer(tlsServer.Close)
+			caPath := filepath.Join(env.WorkDir, "tls-ca.pem")
+			caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+			if err := os.WriteFile(caPath, caPEM, 0o666); err != nil {
+				return err
+			}
+			env.Vars = append(env.Vars, "TLS_SERVER="+tlsServer.URL, "TLS_CA="+caPath)
+
 			ln, err := net.Listen("tcp", ":0")
 			if err != nil {
 				return err
 			}
-			server := &http.Server{Handler: mux}
+			// The server also works as a proxy, tunneling CONNECT requests to
+			// the TLS server, so that https urls can be tested for any host.
+			// Hosts starting with "insecure." don't support https.
+			server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodConnect {
+					mux.ServeHTTP(w, r)
+					return
+				}
+				if strings.HasPrefix(r.Host, "insecure.") {
+					http.Error(w, "", http.StatusBadGateway)
+					return
+				}
+				upstream, err := net.Dial("tcp", tlsServer.Listener.Addr().String())
+				if err != nil {
+					http.Error(w, "", http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				conn, buf, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					upstream.Close()
+					return
+				}
+				go func() {
+					io.Copy(upstream, buf)
+					upstream.Close()
+				}()
+				io.Copy(conn, upstream)
+				conn.Close()
+			})}
 			go server.Serve(ln)
 			env.Vars = append(env.Vars, "SERVER=http://"+ln.Addr().String())
 			env.Defer(func() {
@@ -119,6 +401,190 @@ func TestScript(t *testing.T) {
 					ts.Check(err)
 				}
 			},
+			"interrupt": func(ts *testscript.TestScript, neg bool, args []string) {
+				if len(args) < 2 {
+					ts.Fatalf("usage: interrupt key command [args...]")
+				}
+				runInterrupted(ts, neg, ts.Value(blockerKey{}).(*blocker).get(args[0]), args[1:])
+			},
+			"waitblocked": func(ts *testscript.TestScript, neg bool, args []string) {
+				if neg || len(args) != 1 {
+					ts.Fatalf("usage: waitblocked key")
+				}
+				key := ts.Value(blockerKey{}).(*blocker).get(args[0])
+				select {
+				case <-key.arrived:
+				case <-time.After(time.Minute):
+					ts.Fatalf("no request for /block?k=%s arrived", args[0])
+				}
+			},
+			"unblock": func(ts *testscript.TestScript, neg bool, args []string) {
+				if neg || len(args) != 1 {
+					ts.Fatalf("usage: unblock key")
+				}
+				close(ts.Value(blockerKey{}).(*blocker).get(args[0]).release)
+			},
+			"trimnl": func(ts *testscript.TestScript, neg bool, args []string) {
+				if neg {
+					ts.Fatalf("unsupported: ! trimnl")
+				}
+				if len(args) == 0 {
+					ts.Fatalf("usage: trimnl file...")
+				}
+				// txtar files always end with a newline.
+				for _, arg := range args {
+					data := strings.TrimSuffix(ts.ReadFile(arg), "\n")
+					err := os.WriteFile(ts.MkAbs(arg), []byte(data), 0o666)
+					ts.Check(err)
+				}
+			},
 		},
 	})
 }
+
+// blocker holds the state for the /block handler, per key.
+type blocker struct {
+	mu   sync.Mutex
+	keys map[string]*blockedKey
+}
+
+// blockerKey is the key for the blocker in testscript.Env.Values.
+type blockerKey struct{}
+
+type blockedKey struct {
+	arriveOnce sync.Once
+	arrived    chan struct{} // closed once a request arrives
+	release    chan struct{} // closed by "unblock"
+}
+
+func (b *blocker) get(key string) *blockedKey {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := b.keys[key]
+	if k == nil {
+		k = &blockedKey{arrived: make(chan struct{}), release: make(chan struct{})}
+		b.keys[key] = k
+	}
+	return k
+}
+
+// runInterrupted runs a command which makes a request to /block, and
+// interrupts it while the request is in flight. The request is only let
+// through once the command says that it was interrupted, so that the
+// command can't start any more requests before handling the interrupt.
+func runInterrupted(ts *testscript.TestScript, neg bool, key *blockedKey, args []string) {
+	var path string
+	for _, dir := range filepath.SplitList(ts.Getenv("PATH")) {
+		if _, err := os.Stat(filepath.Join(dir, args[0])); err == nil {
+			path = filepath.Join(dir, args[0])
+			break
+		}
+	}
+	if path == "" {
+		ts.Fatalf("%s not found in $PATH", args[0])
+	}
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Args[0] = args[0] // testscript.Main uses it to pick the command
+	cmd.Dir = ts.MkAbs(".")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	pipe, err := cmd.StderrPipe()
+	ts.Check(err)
+	ts.Check(cmd.Start())
+
+	select {
+	case <-key.arrived:
+	case <-time.After(time.Minute):
+		cmd.Process.Kill()
+		ts.Fatalf("no request to /block arrived")
+	}
+	ts.Check(cmd.Process.Signal(os.Interrupt))
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		fmt.Fprintln(&stderr, scanner.Text())
+		if strings.HasPrefix(scanner.Text(), "interrupted; waiting") {
+			break
+		}
+	}
+	close(key.release)
+	io.Copy(&stderr, pipe)
+	err = cmd.Wait()
+
+	ts.Stdout().Write(stdout.Bytes())
+	ts.Stderr().Write(stderr.Bytes())
+	switch {
+	case err != nil && !neg:
+		ts.Fatalf("unexpected %s failure: %v", args[0], err)
+	case err == nil && neg:
+		ts.Fatalf("unexpected %s success", args[0])
+	}
+}
+
+func TestWriteFixedWithoutOwner(t *testing.T) {
+	// Like when the file belongs to another user, but we can write to it.
+	keepOwner = func(string, os.FileInfo) error { return os.ErrPermission }
+	defer func() { keepOwner = copyOwner }()
+	*backupSuffix = ".orig"
+	defer func() { *backupSuffix = "" }()
+
+	path := filepath.Join(t.TempDir(), "file")
+	orig := []byte("original\n")
+	if err := os.WriteFile(path, orig, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(orig)
+	if err := writeFixed(path, []byte("fixed\n"), sum[:]); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Errorf("file was replaced rather than written in place")
+	}
+	if got, want := after.Mode(), before.Mode(); got != want {
+		t.Errorf("file mode changed from %v to %v", want, got)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "fixed\n" {
+		t.Errorf("file has %q, want the fixed contents", got)
+	}
+	if got, _ := os.ReadFile(path + ".orig"); string(got) != string(orig) {
+		t.Errorf("backup has %q, want the original contents", got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("want only the file and its backup, got %d files", len(entries))
+	}
+}
+
+func TestWriteFixedReadOnlyDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can create files in read-only directories")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	orig := []byte("original\n")
+	if err := os.WriteFile(path, orig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0o755)
+
+	sum := sha256.Sum256(orig)
+	if err := writeFixed(path, []byte("fixed\n"), sum[:]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "fixed\n" {
+		t.Errorf("file has %q, want the fixed contents", got)
+	}
+}
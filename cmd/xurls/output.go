@@ -0,0 +1,232 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"mvdan.cc/xurls/v2"
+)
+
+// matchInfo holds the data about a single match,
+// which is also what -format templates are executed with.
+type matchInfo struct {
+	URL      string // the url as found in the input
+	Scheme   string
+	Host     string // without the port
+	Port     string
+	Path     string
+	Query    string
+	Fragment string
+
+	File   string // "-" for standard input
+	Line   int    // starting at 1
+	Column int    // in bytes, starting at 1
+
+	// Kind is "url" for urls with a scheme, "email" for email addresses
+	// without a scheme, and "domain" for any other match without a scheme.
+	Kind string
+
+	// Count is the number of occurrences of the url, with -count.
+	Count int
+
+	// Status, Method and Redirect are only set with -fix.
+	// Status is the result of loading the url, such as "200 OK",
+	// Method is the HTTP method which gave that status, such as "HEAD",
+	// and Redirect is the url which replaces the original one, if any.
+	Status   string
+	Method   string
+	Redirect string
+}
+
+func newMatchInfo(path string, line, col int, match string) matchInfo {
+	m := matchInfo{
+		URL:    match,
+		File:   path,
+		Line:   line,
+		Column: col,
+		Kind:   "url",
+	}
+	if !hasScheme(match) {
+		m.Kind = "domain"
+		if strings.Contains(match, "@") {
+			m.Kind = "email"
+		}
+	}
+	u, err := parseMatch(match)
+	if err != nil {
+		return m
+	}
+	m.Scheme = u.Scheme
+	m.Host = u.Hostname()
+	m.Port = u.Port()
+	m.Path = u.Path
+	m.Query = u.RawQuery
+	m.Fragment = u.Fragment
+	if m.Host == "" && u.Opaque != "" {
+		// Such as "mailto:user@host" or "xmpp:user@host/resource".
+		if _, host, ok := strings.Cut(u.Opaque, "@"); ok {
+			host, _, _ = strings.Cut(host, "/")
+			m.Host = host
+		}
+	}
+	return m
+}
+
+var formatTmpl *template.Template
+
+// printMatch prints a url found while extracting urls,
+// taking the -u and -count flags into account.
+func printMatch(m matchInfo) {
+	if !*unique && !*countURLs {
+		emitMatch(m)
+		return
+	}
+	key := dedupKey(m.URL)
+	if seen := seenURLs[key]; seen != nil {
+		seen.Count++
+		return
+	}
+	m.Count = 1
+	seen := &m
+	seenURLs[key] = seen
+	seenOrder = append(seenOrder, seen)
+	if !*countURLs {
+		// With -u, we can print the first occurrence right away.
+		emitMatch(m)
+	}
+}
+
+// emitMatch prints a match, or adds it to the report with -stats.
+func emitMatch(m matchInfo) {
+	if statsFlag != "" {
+		stats.add(m)
+		return
+	}
+	writeMatch(os.Stdout, m)
+}
+
+// writeMatch writes a single match to w, followed by a newline or a null
+// byte with -0. The match is formatted with -format if given.
+func writeMatch(w io.Writer, m matchInfo) {
+	switch {
+	case formatTmpl != nil:
+		if err := formatTmpl.Execute(w, m); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case *countURLs:
+		fmt.Fprintf(w, "%d %s", m.Count, m.URL)
+	default:
+		io.WriteString(w, m.URL)
+	}
+	io.WriteString(w, separator())
+}
+
+// separator returns what follows each printed url or file name:
+// a newline, or a null byte with -0.
+func separator() string {
+	if *nullSep {
+		return "\x00"
+	}
+	return "\n"
+}
+
+var (
+	// seenURLs holds the urls found so far across all input files,
+	// keyed by dedupKey, for -u and -count.
+	seenURLs = make(map[string]*matchInfo)
+
+	// seenOrder holds the same urls as seenURLs in order of first occurrence.
+	seenOrder []*matchInfo
+)
+
+// printCounts prints the urls found with -count, once all input files
+// have been scanned. The most frequent urls come first, and urls with the
+// same count are kept in order of first occurrence.
+func printCounts() {
+	slices.SortStableFunc(seenOrder, func(a, b *matchInfo) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	for _, seen := range seenOrder {
+		writeMatch(os.Stdout, *seen)
+	}
+}
+
+func dedupKey(match string) string {
+	if !*normalize {
+		return match
+	}
+	return normalizeURL(match)
+}
+
+// hasScheme reports whether a match starts with a scheme,
+// which is not the case for some matches found in relaxed mode.
+func hasScheme(match string) bool {
+	scheme, rest, ok := strings.Cut(match, ":")
+	if !ok || scheme == "" {
+		return false
+	}
+	for i, r := range scheme {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	if strings.HasPrefix(rest, "//") {
+		return true
+	}
+	// Not something like "foo.com:8080/path".
+	return slices.ContainsFunc(xurls.SchemesNoAuthority, func(s string) bool {
+		return strings.EqualFold(s, scheme)
+	})
+}
+
+// parseMatch parses a url found by the regular expression.
+// Matches without a scheme, such as domains or email addresses found in
+// relaxed mode, are parsed as if they had an empty scheme, so that the host
+// is still available.
+func parseMatch(match string) (*url.URL, error) {
+	if hasScheme(match) {
+		return url.Parse(match)
+	}
+	return url.Parse("//" + match)
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// normalizeURL returns a canonical form of a url, so that equivalent urls
+// such as "http://Example.com" and "http://example.com:80/" are equal.
+// Scheme and host are lowercased, default ports are removed, and an empty
+// path becomes "/". Urls which cannot be parsed are returned as-is.
+func normalizeURL(match string) string {
+	u, err := parseMatch(match)
+	if err != nil {
+		return match
+	}
+	// Note that url.Parse already lowercases the scheme.
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
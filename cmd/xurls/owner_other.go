@@ -0,0 +1,12 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build !unix
+
+package main
+
+import "os"
+
+// copyOwner does nothing, as file ownership works differently
+// on non-unix systems.
+func copyOwner(path string, info os.FileInfo) error { return nil }
@@ -0,0 +1,29 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner sets the owner and group of a file to match another file's.
+// Nothing is done if they already match, as changing the owner of a file
+// usually requires special privileges.
+func copyOwner(path string, info os.FileInfo) error {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	cur, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if got, ok := cur.Sys().(*syscall.Stat_t); ok && got.Uid == want.Uid && got.Gid == want.Gid {
+		return nil
+	}
+	return os.Chown(path, int(want.Uid), int(want.Gid))
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// hostPolicy configures how the results of loading urls from some hosts are
+// treated, as some hosts always reject bots with status codes like 403 or 999,
+// and some internal endpoints legitimately require authentication.
+type hostPolicy struct {
+	// Accept lists the status codes which don't make a url broken.
+	Accept []int `json:"accept"`
+
+	// Action is one of:
+	//
+	//   - "ok": urls are never broken, but redirects are still followed
+	//   - "skip": urls aren't loaded at all, like non-http urls
+	//   - "warn": broken urls are reported as warnings, which don't fail
+	Action string `json:"action"`
+}
+
+// policyRule is a host policy from the configuration,
+// with its host pattern compiled.
+type policyRule struct {
+	host *regexp.Regexp
+	hostPolicy
+}
+
+// policies is set from the "policies" in the configuration file.
+var policies []policyRule
+
+// parsePolicies compiles and validates the per-host policies from the
+// configuration. They are sorted with the longest host patterns first,
+// as those tend to be the most specific, and then in lexical order.
+func parsePolicies(cfg *config) ([]policyRule, error) {
+	patterns := slices.Collect(maps.Keys(cfg.Policies))
+	slices.SortFunc(patterns, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), cmp.Compare(a, b))
+	})
+	var list []policyRule
+	for _, pattern := range patterns {
+		pol := cfg.Policies[pattern]
+		switch pol.Action {
+		case "", "ok", "skip", "warn":
+		default:
+			return nil, fmt.Errorf("policy for %q: unknown action %q", pattern, pol.Action)
+		}
+		for _, code := range pol.Accept {
+			if code < 100 || code > 999 {
+				return nil, fmt.Errorf("policy for %q: invalid status code: %d", pattern, code)
+			}
+		}
+		rx, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, policyRule{rx, pol})
+	}
+	return list, nil
+}
+
+// policyFor returns the policy for a host. If multiple host patterns match,
+// the longest one is used.
+func policyFor(host string) hostPolicy {
+	for _, rule := range policies {
+		if rule.host.MatchString(host) {
+			return rule.hostPolicy
+		}
+	}
+	return hostPolicy{}
+}
+
+// apply applies the policy to the result of loading a url.
+func (p hostPolicy) apply(res checkResult) checkResult {
+	switch {
+	case !res.broken:
+	case res.code != 0 && slices.Contains(p.Accept, res.code):
+		res.broken = false
+	case p.Action == "ok":
+		res.broken = false
+	case p.Action == "warn":
+		res.broken = false
+		res.warning = res.status
+	}
+	return res
+}
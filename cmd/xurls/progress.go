@@ -0,0 +1,106 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress counts the urls found and checked with -fix or -check.
+type progress struct {
+	found      atomic.Int64
+	checked    atomic.Int64
+	broken     atomic.Int64
+	redirected atomic.Int64
+}
+
+var prog progress
+
+func (p *progress) String() string {
+	return fmt.Sprintf("checked %d/%d urls, %d broken, %d redirected",
+		p.checked.Load(), p.found.Load(), p.broken.Load(), p.redirected.Load())
+}
+
+// progressPrinter prints the progress to a writer periodically, for -progress.
+// On a terminal, a single line is updated in place.
+// Otherwise, a new line is printed every few seconds if there was progress.
+type progressPrinter struct {
+	w        io.Writer
+	terminal bool
+
+	mu      sync.Mutex
+	last    string // last printed progress line
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+func startProgress(f *os.File) *progressPrinter {
+	p := &progressPrinter{
+		w:       f,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	interval := 5 * time.Second
+	if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		p.terminal = true
+		interval = 200 * time.Millisecond
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print(false)
+			case <-p.stopped:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progressPrinter) print(final bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	line := prog.String()
+	switch {
+	case p.terminal:
+		// Carriage return, then erase the rest of the line.
+		fmt.Fprintf(p.w, "\r%s\x1b[K", line)
+		if final {
+			fmt.Fprintln(p.w)
+		}
+	case line != p.last || final:
+		fmt.Fprintln(p.w, line)
+	}
+	p.last = line
+}
+
+// printf prints a message, such as the urls found in a file, making sure
+// that it doesn't get mixed up with the progress line on a terminal.
+func (p *progressPrinter) printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.terminal {
+		io.WriteString(p.w, "\r\x1b[K")
+	}
+	fmt.Fprintf(p.w, format, args...)
+	if p.terminal {
+		fmt.Fprintf(p.w, "%s\x1b[K", p.last)
+	}
+}
+
+// stop stops printing the progress periodically, and prints it one last time.
+func (p *progressPrinter) stop() {
+	close(p.stopped)
+	<-p.done
+	p.print(true)
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fileReport holds the results of checking the urls in one input file,
+// for -report.
+type fileReport struct {
+	path    string
+	matches []matchInfo
+	broken  []finding
+}
+
+// fileReports holds the results for all input files, in order.
+var fileReports []fileReport
+
+// reportFormats are the supported values for -report.
+var reportFormats = map[string]func(io.Writer, []fileReport) error{
+	"json":   writeJSONReport,
+	"sarif":  writeSARIFReport,
+	"junit":  writeJUnitReport,
+	"github": writeGitHubReport,
+}
+
+// writeReport writes the report for -report, if any, to -report-file or
+// standard output.
+func writeReport() {
+	if *reportFormat == "" {
+		return
+	}
+	if *reportFile == "" {
+		if err := reportFormats[*reportFormat](os.Stdout, fileReports); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	f, err := os.Create(*reportFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = reportFormats[*reportFormat](f, fileReports)
+	// A failed close may mean that the report was truncated.
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type jsonBrokenURL struct {
+	File      string        `json:"file"`
+	Line      int           `json:"line"`
+	Column    int           `json:"column"`
+	URL       string        `json:"url"`
+	Reason    string        `json:"reason"`
+	Status    int           `json:"status,omitempty"`
+	Method    string        `json:"method,omitempty"`
+	Redirects []redirectHop `json:"redirects,omitempty"`
+}
+
+func writeJSONReport(w io.Writer, reports []fileReport) error {
+	list := []jsonBrokenURL{} // encode an empty list as [] rather than null
+	for _, report := range reports {
+		for _, broken := range report.broken {
+			list = append(list, jsonBrokenURL{
+				File:      report.path,
+				Line:      broken.line,
+				Column:    broken.col,
+				URL:       broken.url,
+				Reason:    broken.reason,
+				Status:    broken.code,
+				Method:    broken.method,
+				Redirects: broken.redirects,
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(list)
+}
+
+// brokenMessage describes a broken url, including any redirects followed,
+// such as "http://a - 404 Not Found (redirects: http://a -301-> http://b)".
+func brokenMessage(broken finding) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s", broken.url, broken.reason)
+	if len(broken.redirects) > 0 {
+		fmt.Fprintf(&b, " (redirects: %s)", redirectChain(broken.redirects))
+	}
+	return b.String()
+}
+
+// redirectChain formats a list of redirects as "a -301-> b -302-> c".
+func redirectChain(hops []redirectHop) string {
+	var b strings.Builder
+	for i, hop := range hops {
+		if i == 0 {
+			b.WriteString(hop.URL)
+		}
+		fmt.Fprintf(&b, " -%d-> %s", hop.Code, hop.Target)
+	}
+	return b.String()
+}
+
+// SARIF 2.1.0, as documented at https://docs.oasis-open.org/sarif/sarif/v2.1.0/.
+// We only use the bits we need.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifProperties struct {
+	Method string `json:"method,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndColumn   int `json:"endColumn"`
+}
+
+const sarifBrokenRule = "broken-url"
+
+func writeSARIFReport(w io.Writer, reports []fileReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "xurls",
+			Version:        readVersion(),
+			InformationURI: "https://github.com/mvdan/xurls",
+			Rules: []sarifRule{{
+				ID:               sarifBrokenRule,
+				ShortDescription: sarifMessage{"The url failed to load."},
+			}},
+		}},
+		Results: []sarifResult{},
+	}
+	for _, report := range reports {
+		for _, broken := range report.broken {
+			result := sarifResult{
+				RuleID:  sarifBrokenRule,
+				Level:   "error",
+				Message: sarifMessage{brokenMessage(broken)},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: report.path},
+					Region: sarifRegion{
+						StartLine:   broken.line,
+						StartColumn: broken.col,
+						EndColumn:   broken.col + len(broken.url),
+					},
+				}}},
+			}
+			if broken.method != "" {
+				result.Properties = &sarifProperties{Method: broken.method}
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// JUnit XML, as understood by most CI systems.
+// Each input file is a test suite, and each url checked in it is a test case.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnitReport(w io.Writer, reports []fileReport) error {
+	var root junitTestSuites
+	for _, report := range reports {
+		type position struct{ line, col int }
+		broken := make(map[position]finding)
+		for _, b := range report.broken {
+			broken[position{b.line, b.col}] = b
+		}
+		suite := junitTestSuite{Name: report.path}
+		for _, m := range report.matches {
+			tc := junitTestCase{
+				Name:      fmt.Sprintf("%d:%d: %s", m.Line, m.Column, m.URL),
+				Classname: report.path,
+			}
+			if b, ok := broken[position{m.Line, m.Column}]; ok {
+				tc.Failure = &junitFailure{
+					Message: b.reason,
+					Text:    fmt.Sprintf("%s:%d:%d: %s", report.path, b.line, b.col, brokenMessage(b)),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+		}
+		root.Tests += suite.Tests
+		root.Failures += suite.Failures
+		root.Suites = append(root.Suites, suite)
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// GitHub Actions workflow commands, which show up as annotations on pull
+// requests. See https://docs.github.com/en/actions/reference/workflow-commands-for-github-actions.
+var (
+	githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func writeGitHubReport(w io.Writer, reports []fileReport) error {
+	for _, report := range reports {
+		for _, broken := range report.broken {
+			_, err := fmt.Fprintf(w, "::error file=%s,line=%d,col=%d,title=%s::%s\n",
+				githubPropEscaper.Replace(report.path), broken.line, broken.col,
+				githubPropEscaper.Replace("broken url"),
+				githubDataEscaper.Replace(brokenMessage(broken)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
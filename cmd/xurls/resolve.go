@@ -0,0 +1,69 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// resolve is set by -resolve, to "all" or "short".
+var resolve boolString
+
+// shortenerHosts are the hosts of well known url shorteners,
+// whose urls are resolved with -resolve=short.
+var shortenerHosts = map[string]bool{
+	"aka.ms":      true,
+	"amzn.to":     true,
+	"bit.ly":      true,
+	"bitly.com":   true,
+	"buff.ly":     true,
+	"cutt.ly":     true,
+	"db.tt":       true,
+	"fb.me":       true,
+	"goo.gl":      true,
+	"is.gd":       true,
+	"lnkd.in":     true,
+	"ow.ly":       true,
+	"rb.gy":       true,
+	"rebrand.ly":  true,
+	"s.id":        true,
+	"shorturl.at": true,
+	"t.co":        true,
+	"t.ly":        true,
+	"tiny.cc":     true,
+	"tinyurl.com": true,
+	"trib.al":     true,
+	"v.gd":        true,
+	"youtu.be":    true,
+}
+
+// resolvable reports whether a url should be resolved with -resolve.
+// Only http and https urls can be resolved.
+func resolvable(match string) bool {
+	u, err := url.Parse(match)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if resolve == "short" {
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		return shortenerHosts[host]
+	}
+	return true
+}
+
+// resolvedChain formats the redirects followed for a url, as well as the final
+// status code or error, such as "http://a -301-> http://b -302-> http://c 200".
+func resolvedChain(match string, res checkResult) string {
+	chain := match
+	if len(res.redirects) > 0 {
+		chain = redirectChain(res.redirects)
+	}
+	if res.code == 0 || res.status == errRedirectLoop.Error() {
+		// The request failed, so we don't have a final status code.
+		return fmt.Sprintf("%s (%s)", chain, res.status)
+	}
+	return fmt.Sprintf("%s %d", chain, res.code)
+}
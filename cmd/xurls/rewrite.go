@@ -0,0 +1,191 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// rewriteURL returns the text which should replace a url in the input,
+// given the url it should be replaced with, such as a redirect target.
+//
+// The original url is kept if the target is equivalent to it,
+// as url.URL.String may spell the same url differently.
+// Otherwise, the target is written in the style of the original url where
+// possible; see restyleURL.
+func rewriteURL(orig, target string) string {
+	if target == "" || equivalentURLs(orig, target) {
+		return orig
+	}
+	return restyleURL(orig, target)
+}
+
+// equivalentURLs reports whether two urls point to the same resource,
+// ignoring differences such as the case of percent-encodings, or non-ASCII
+// characters which are percent-encoded in one url but not in the other.
+func equivalentURLs(url1, url2 string) bool {
+	return normalizeURL(canonicalEscapes(url1)) == normalizeURL(canonicalEscapes(url2))
+}
+
+// canonicalEscapes percent-encodes all non-ASCII bytes in a url, decodes any
+// percent-encoded unreserved characters like "~", and uppercases the rest.
+func canonicalEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			b.WriteString(percentEncode(c))
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteString(percentEncode(decoded))
+			}
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// restyleURL rewrites a target url to follow the style of the original one:
+//
+//   - if the original has non-ASCII characters, as an IRI would,
+//     percent-encoded non-ASCII characters in the target are decoded
+//   - if the original uses lowercase percent-encodings, so does the target
+//   - if both have the same host, the original's host case is kept
+//   - if the original has no path, a target path of "/" is dropped
+func restyleURL(orig, target string) string {
+	origURL, err1 := url.Parse(orig)
+	targetURL, err2 := url.Parse(target)
+	if err1 != nil || err2 != nil {
+		return target
+	}
+	if origURL.Path == "" && origURL.Opaque == "" && targetURL.Path == "/" {
+		prefix := targetURL.Scheme + "://" + targetURL.Host
+		if rest, ok := strings.CutPrefix(target, prefix+"/"); ok {
+			target = prefix + rest
+		}
+	}
+	if hasNonASCII(orig) {
+		target = decodeNonASCII(target)
+	} else if hasLowerEscapes(orig) {
+		target = lowerEscapes(target)
+	}
+	if origURL.Host != targetURL.Host && strings.EqualFold(origURL.Host, targetURL.Host) && targetURL.User == nil {
+		prefix := targetURL.Scheme + "://"
+		if rest, ok := strings.CutPrefix(target, prefix); ok && strings.HasPrefix(rest, targetURL.Host) {
+			target = prefix + origURL.Host + rest[len(targetURL.Host):]
+		}
+	}
+	return target
+}
+
+// decodeNonASCII decodes the percent-encoded sequences in a url which are
+// valid UTF-8 for non-ASCII characters, such as "%C3%A9" for "é".
+func decodeNonASCII(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// Find a run of percent-encoded non-ASCII bytes.
+		var run []byte
+		j := i
+		for j+2 < len(s) && s[j] == '%' && isHex(s[j+1]) && isHex(s[j+2]) {
+			c := unhex(s[j+1])<<4 | unhex(s[j+2])
+			if c < utf8.RuneSelf {
+				break
+			}
+			run = append(run, c)
+			j += 3
+		}
+		if len(run) > 0 && utf8.Valid(run) {
+			b.Write(run)
+			i = j
+			continue
+		}
+		if j > i {
+			b.WriteString(s[i:j])
+			i = j
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLowerEscapes reports whether a url uses lowercase percent-encodings,
+// such as "%c3%a9", and no uppercase ones.
+func hasLowerEscapes(s string) bool {
+	lower := false
+	for i := 0; i+2 < len(s); i++ {
+		if s[i] != '%' || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			continue
+		}
+		for _, c := range s[i+1 : i+3] {
+			switch {
+			case 'a' <= c && c <= 'f':
+				lower = true
+			case 'A' <= c && c <= 'F':
+				return false
+			}
+		}
+	}
+	return lower
+}
+
+func lowerEscapes(s string) string {
+	b := []byte(s)
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] == '%' && isHex(b[i+1]) && isHex(b[i+2]) {
+			copy(b[i+1:i+3], strings.ToLower(string(b[i+1:i+3])))
+			i += 2
+		}
+	}
+	return string(b)
+}
+
+func percentEncode(c byte) string {
+	const upperhex = "0123456789ABCDEF"
+	return string([]byte{'%', upperhex[c>>4], upperhex[c&15]})
+}
+
+// isUnreserved reports whether a byte is an unreserved character per RFC 3986,
+// which never needs to be percent-encoded.
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	case c == '-', c == '.', c == '_', c == '~':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
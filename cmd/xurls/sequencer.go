@@ -3,7 +3,8 @@
 // license that can be found in the LICENSE file.
 
 // The code below is borrowed from Go's cmd/gofmt as of 1.18beta1.
-// We tweaked it slightly to add the "broken URLs" result.
+// We tweaked it to collect the matches and findings for each input file,
+// to skip tasks once the context is done, and to get the final state.
 
 package main
 
@@ -18,18 +19,21 @@ import (
 // A sequencer performs concurrent tasks that may write output, but emits that
 // output in a deterministic order.
 type sequencer struct {
+	ctx       context.Context
 	maxWeight int64
 	sem       *semaphore.Weighted   // weighted by input bytes (an approximate proxy for memory overhead)
 	prev      <-chan *reporterState // 1-buffered
 }
 
 // newSequencer returns a sequencer that allows concurrent tasks up to maxWeight
-// and writes tasks' output to out and err.
-func newSequencer(maxWeight int64, out, err io.Writer) *sequencer {
+// and writes tasks' output to out and err. Once ctx is done, tasks which are
+// still waiting to be executed are skipped.
+func newSequencer(ctx context.Context, maxWeight int64, out, err io.Writer) *sequencer {
 	sem := semaphore.NewWeighted(maxWeight)
 	prev := make(chan *reporterState, 1)
 	prev <- &reporterState{out: out, err: err}
 	return &sequencer{
+		ctx:       ctx,
 		maxWeight: maxWeight,
 		sem:       sem,
 		prev:      prev,
@@ -56,10 +60,10 @@ func (s *sequencer) Add(weight int64, f func(*reporter) error) {
 	if weight < 0 || weight > s.maxWeight {
 		weight = s.maxWeight
 	}
-	if err := s.sem.Acquire(context.TODO(), weight); err != nil {
-		// Change the task from "execute f" to "report err".
+	if err := s.sem.Acquire(s.ctx, weight); err != nil {
+		// The sequence was canceled; skip the task.
 		weight = 0
-		f = func(*reporter) error { return err }
+		f = func(*reporter) error { return nil }
 	}
 
 	r := &reporter{prev: s.prev}
@@ -88,13 +92,14 @@ func (s *sequencer) GetExitCode() int {
 	return <-c
 }
 
+// finalState waits for all previously-added tasks to complete, then returns
+// the final reporter state. Unlike Add, it works even if ctx is done.
 func (s *sequencer) finalState() reporterState {
-	c := make(chan reporterState, 1)
-	s.Add(0, func(r *reporter) error {
-		c <- *r.getState()
-		return nil
-	})
-	return <-c
+	state := <-s.prev
+	next := make(chan *reporterState, 1)
+	next <- state
+	s.prev = next
+	return *state
 }
 
 // A reporter reports output, warnings, and errors.
@@ -110,12 +115,44 @@ type reporterState struct {
 	out, err io.Writer
 	exitCode int
 
-	brokenURLs []brokenURL
+	findings []finding
+	matches  []matchInfo
+}
+
+// ofKind returns the findings of one kind, in order.
+func (s *reporterState) ofKind(kind findingKind) []finding {
+	var list []finding
+	for _, f := range s.findings {
+		if f.kind == kind {
+			list = append(list, f)
+		}
+	}
+	return list
 }
 
-type brokenURL struct {
-	url    string
-	reason string
+// findingKind is the kind of a finding, which decides how it is reported.
+type findingKind int
+
+const (
+	brokenFinding   findingKind = iota // a url which failed to load
+	insecureFinding                    // an http url without a working https version
+	warningFinding                     // a broken url with a "warn" host policy
+	redirectFinding                    // a url which redirects to target
+	replacedFinding                    // a dead url replaced with target by -fix=dead
+)
+
+// finding is a url which is reported, found at a line and column of the
+// input, both starting at 1.
+type finding struct {
+	kind      findingKind
+	url       string
+	line, col int
+	reason    string // why the url is reported
+	target    string // for redirects and replacements, the new url
+
+	code      int    // final HTTP status code, if any
+	method    string // HTTP method which gave the final status, if any
+	redirects []redirectHop
 }
 
 // getState blocks until any prior reporters are finished with the reporter
@@ -135,9 +172,14 @@ func (r *reporter) Write(p []byte) (int, error) {
 	return r.getState().out.Write(p)
 }
 
-func (r *reporter) appendBroken(url, reason string) {
+func (r *reporter) appendFinding(f finding) {
+	state := r.getState()
+	state.findings = append(state.findings, f)
+}
+
+func (r *reporter) appendMatch(info matchInfo) {
 	state := r.getState()
-	state.brokenURLs = append(state.brokenURLs, brokenURL{url, reason})
+	state.matches = append(state.matches, info)
 }
 
 // Report emits a non-nil error to the reporter's error stream,
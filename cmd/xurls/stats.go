@@ -0,0 +1,157 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// urlStats aggregates the urls found across all input files for -stats.
+type urlStats struct {
+	total int
+
+	// Each group maps a key, such as a scheme, to its number of urls.
+	schemes map[string]int
+	hosts   map[string]int
+	domains map[string]int
+	files   map[string]int
+	kinds   map[string]int
+}
+
+var stats = urlStats{
+	schemes: make(map[string]int),
+	hosts:   make(map[string]int),
+	domains: make(map[string]int),
+	files:   make(map[string]int),
+	kinds:   make(map[string]int),
+}
+
+// noneKey is used in place of empty keys, such as urls without a scheme.
+const noneKey = "(none)"
+
+func keyOrNone(s string) string {
+	if s == "" {
+		return noneKey
+	}
+	return s
+}
+
+func (s *urlStats) add(m matchInfo) {
+	s.total++
+	host := strings.ToLower(m.Host)
+	s.schemes[keyOrNone(strings.ToLower(m.Scheme))]++
+	s.hosts[keyOrNone(host)]++
+	s.domains[keyOrNone(registrableDomain(host))]++
+	s.files[m.File]++
+	s.kinds[m.Kind]++
+}
+
+// registrableDomain returns the domain under which a host is registered,
+// such as "example.co.uk" for "www.example.co.uk", or "foo.github.io" for
+// "www.foo.github.io", per the public suffix list.
+// IP addresses, as well as hosts which are a public suffix, are returned as-is.
+func registrableDomain(host string) string {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// statsEntry is a single key in a group, sorted by count.
+type statsEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type statsGroup struct {
+	name    string
+	Entries []statsEntry `json:"entries"`
+	// Others is the number of urls in the entries omitted by -top.
+	Others int `json:"others,omitempty"`
+}
+
+// topEntries sorts a group by decreasing count, with ties sorted by name,
+// and keeps at most the first n entries if n is positive.
+func topEntries(name string, counts map[string]int, n int) statsGroup {
+	g := statsGroup{name: name}
+	for key, count := range counts {
+		g.Entries = append(g.Entries, statsEntry{key, count})
+	}
+	slices.SortFunc(g.Entries, func(a, b statsEntry) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	if n > 0 && len(g.Entries) > n {
+		for _, e := range g.Entries[n:] {
+			g.Others += e.Count
+		}
+		g.Entries = g.Entries[:n]
+	}
+	return g
+}
+
+// statsReport is the output of -stats, which is also encoded as JSON.
+type statsReport struct {
+	Total   int        `json:"total"`
+	Schemes statsGroup `json:"schemes"`
+	Hosts   statsGroup `json:"hosts"`
+	Domains statsGroup `json:"domains"`
+	Files   statsGroup `json:"files"`
+	Kinds   statsGroup `json:"kinds"`
+}
+
+func (s *urlStats) report(top int) statsReport {
+	return statsReport{
+		Total:   s.total,
+		Schemes: topEntries("scheme", s.schemes, top),
+		Hosts:   topEntries("host", s.hosts, top),
+		Domains: topEntries("domain", s.domains, top),
+		Files:   topEntries("file", s.files, top),
+		Kinds:   topEntries("kind", s.kinds, top),
+	}
+}
+
+// print writes the report for -stats, or -stats=json.
+func (s *urlStats) print(w io.Writer, top int, asJSON bool) error {
+	report := s.report(top)
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(report)
+	}
+	fmt.Fprintf(w, "total: %d urls\n", report.Total)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, g := range []statsGroup{report.Schemes, report.Hosts, report.Domains, report.Files, report.Kinds} {
+		fmt.Fprintf(tw, "\n%s\turls\t%%\n", g.name)
+		row := func(name string, count int) {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f\n", name, count, 100*float64(count)/float64(report.Total))
+		}
+		for _, e := range g.Entries {
+			row(e.Name, e.Count)
+		}
+		if g.Others > 0 {
+			row("(others)", g.Others)
+		}
+		// Flush per group, so that each table is aligned on its own.
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
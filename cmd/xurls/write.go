@@ -0,0 +1,129 @@
+// Copyright (c) 2026, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFixed replaces the contents of a file fixed by -fix.
+// origSum is the SHA-256 sum of the contents which were read, so that we
+// don't overwrite any changes made to the file since then.
+//
+// The file is written atomically, by writing a temporary file in the same
+// directory and renaming it over the original, so that the file is never left
+// partially written. The original file's mode and ownership are kept.
+// If the path is a symlink, the file it points to is replaced instead,
+// so that the symlink is kept.
+//
+// If the owner can't be kept, such as when the file belongs to another user
+// and is writable via its group, or if the directory isn't writable, the file
+// is written in place instead. That isn't atomic, but it's how we wrote files
+// before.
+func writeFixed(path string, data, origSum []byte) error {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s: not a regular file", path)
+	}
+	current, err := os.ReadFile(realPath)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(current); !bytes.Equal(sum[:], origSum) {
+		return fmt.Errorf("%s: file changed while checking its urls; not overwriting it", path)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(realPath), "."+filepath.Base(realPath)+".xurls-*")
+	if errors.Is(err, fs.ErrPermission) {
+		// We can't create files in the directory, but we may still be
+		// able to write to the file itself.
+		return writeInPlace(realPath, data, info)
+	}
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	// Removing the file after the rename is harmless, as it fails.
+	defer os.Remove(tmpPath)
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	// Note that os.CreateTemp uses a mode of 0o600.
+	if err := os.Chmod(tmpPath, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	if err := keepOwner(tmpPath, info); err != nil {
+		return writeInPlace(realPath, data, info)
+	}
+	if *backupSuffix != "" {
+		if err := backupFile(realPath, realPath+*backupSuffix, info, true); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, realPath)
+}
+
+// writeInPlace truncates and rewrites a file, which keeps its owner and mode.
+func writeInPlace(path string, data []byte, info os.FileInfo) error {
+	if *backupSuffix != "" {
+		// A hard link would share the contents we are about to replace.
+		if err := backupFile(path, path+*backupSuffix, info, false); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
+
+// backupFile keeps a copy of a file before it is replaced, for -backup.
+// If link is true, a hard link is used where possible, as it keeps the file's
+// metadata and doesn't need to copy its contents.
+func backupFile(path, backupPath string, info os.FileInfo, link bool) error {
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if link {
+		if err := os.Link(path, backupPath); err == nil {
+			return nil
+		}
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err2 := dst.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// keepOwner is copyOwner, replaced by the tests to simulate a lack of
+// permissions.
+var keepOwner = copyOwner
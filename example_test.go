@@ -28,6 +28,16 @@ func ExampleStrictMatchingScheme() {
 	// [https://foo.com/dl]
 }
 
+func ExampleRelaxedMatchingScheme() {
+	rx, err := xurls.RelaxedMatchingScheme(`https://|mailto:`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(rx.FindAllString("Visit foo.com or https://foo.com/dl, or email mailto:dev@foo.com", -1))
+	// Output:
+	// [foo.com https://foo.com/dl mailto:dev@foo.com]
+}
+
 func Example_filterEmails() {
 	s := "Email dev@foo.com about any issues with foo.com or https://foo.com/dl"
 	rx := xurls.Relaxed()
@@ -4,10 +4,11 @@ go 1.25.0
 
 require (
 	github.com/rogpeppe/go-internal v1.14.1
+	golang.org/x/net v0.58.0
 	golang.org/x/sync v0.20.0
 )
 
 require (
-	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
 	golang.org/x/tools v0.28.0 // indirect
 )
@@ -146,7 +146,9 @@ func strictExp() string {
 	return schemes + pathCont
 }
 
-func relaxedExp() string {
+// relaxedExp extends a regular expression matching urls with a scheme,
+// such as strictExp, to also match urls and email addresses with no scheme.
+func relaxedExp(strict string) string {
 	var asciiTLDs, unicodeTLDs []string
 	for i, tld := range TLDs {
 		if tld[0] >= utf8.RuneSelf {
@@ -166,7 +168,7 @@ func relaxedExp() string {
 	hostName := `(?:` + domain + `|\[` + ipv6Addr + `\]|\b` + ipv4Addr + `\b)`
 	webURL := hostName + port + `(?:/` + pathCont + `|/)?`
 	email := `(?P<relaxedEmail>[a-zA-Z0-9._%\-+]+@` + domain + `)`
-	return strictExp() + `|` + webURL + `|` + email + `|` + ipv6AddrMinusEmpty
+	return strict + `|` + webURL + `|` + email + `|` + ipv6AddrMinusEmpty
 }
 
 // Strict produces a regexp that matches any URL with a scheme in either the
@@ -186,7 +188,7 @@ func Strict() *regexp.Regexp {
 // which can be used to filter them as needed.
 func Relaxed() *regexp.Regexp {
 	relaxedInit.Do(func() {
-		relaxedRe = regexp.MustCompile(relaxedExp())
+		relaxedRe = regexp.MustCompile(relaxedExp(strictExp()))
 		relaxedRe.Longest()
 	})
 	return relaxedRe
@@ -195,11 +197,31 @@ func Relaxed() *regexp.Regexp {
 // StrictMatchingScheme produces a regexp similar to Strict, but requiring that
 // the scheme match the given regular expression. See AnyScheme too.
 func StrictMatchingScheme(exp string) (*regexp.Regexp, error) {
-	strictMatching := `(?i)(?:` + exp + `)(?-i)` + pathCont
-	re, err := regexp.Compile(strictMatching)
+	re, err := regexp.Compile(strictMatchingExp(exp))
 	if err != nil {
 		return nil, err
 	}
 	re.Longest()
 	return re, nil
 }
+
+// RelaxedMatchingScheme produces a regexp similar to Relaxed, but requiring
+// that the scheme of any URL with a scheme match the given regular expression.
+// URLs and email addresses with no scheme are matched just like with Relaxed.
+// See AnyScheme too.
+//
+// Note that the rest of a URL whose scheme does not match may still be matched
+// as a URL with no scheme, such as "foo.com/bar" in "ftp://foo.com/bar" when
+// only matching the "https://" scheme.
+func RelaxedMatchingScheme(exp string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(relaxedExp(strictMatchingExp(exp)))
+	if err != nil {
+		return nil, err
+	}
+	re.Longest()
+	return re, nil
+}
+
+func strictMatchingExp(exp string) string {
+	return `(?:(?i)(?:` + exp + `)(?-i)` + pathCont + `)`
+}
@@ -407,6 +407,30 @@ func TestStrictMatchingSchemeAny(t *testing.T) {
 	})
 }
 
+func TestRelaxedMatchingScheme(t *testing.T) {
+	relaxedMatching, _ := RelaxedMatchingScheme("https://|mailto:")
+	doTest(t, "RelaxedMatchingScheme", relaxedMatching, []testCase{
+		{`foo.com`, true},
+		{`foo.com/bar`, true},
+		{`foo@bar.com`, true},
+		{`https://foo`, true},
+		{`HTTPS://foo`, true},
+		{`mailto:foo`, true},
+		{`mailto:foo@bar.com`, true},
+		{`http://foo`, nil},
+		{`ftp://foo.com/bar`, `foo.com/bar`},
+		{`sms:123`, nil},
+		{`1.1.1.1`, true},
+		{`[::1]:8080/path`, true},
+	})
+}
+
+func TestRelaxedMatchingSchemeError(t *testing.T) {
+	if _, err := RelaxedMatchingScheme("bad(regexp"); err == nil {
+		t.Fatal("expected an error for an invalid regexp")
+	}
+}
+
 func bench(b *testing.B, re func() *regexp.Regexp, str string) {
 	b.ReportAllocs()
 	b.SetBytes(int64(len(str)))
@@ -470,3 +494,27 @@ func BenchmarkStrictMatchingScheme_none(b *testing.B) {
 func BenchmarkStrictMatchingScheme_many(b *testing.B) {
 	bench(b, matchingScheme, inputMany)
 }
+
+var (
+	rxRelaxedMatchingScheme     *regexp.Regexp
+	rxRelaxedMatchingSchemeOnce sync.Once
+)
+
+func relaxedMatchingScheme() *regexp.Regexp {
+	rxRelaxedMatchingSchemeOnce.Do(func() {
+		rx, err := RelaxedMatchingScheme("https?://")
+		if err != nil {
+			panic(err)
+		}
+		rxRelaxedMatchingScheme = rx
+	})
+	return rxRelaxedMatchingScheme
+}
+
+func BenchmarkRelaxedMatchingScheme_none(b *testing.B) {
+	bench(b, relaxedMatchingScheme, inputNone)
+}
+
+func BenchmarkRelaxedMatchingScheme_many(b *testing.B) {
+	bench(b, relaxedMatchingScheme, inputMany)
+}